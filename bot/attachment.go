@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"runtime/debug"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// TryThrottleAttachments charges uploaded attachments and stickers against the channel's attachment quota,
+// and deletes the message when the quota is exceeded. Returns true if the message has been deleted, or would
+// have been in shadow mode.
+func (b *Bot) TryThrottleAttachments(m *gateway.MessageCreateEvent) (deleted bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("PANIC: %+v\n%s", err, debug.Stack())
+		}
+	}()
+
+	count := len(m.Attachments) + len(m.Stickers)
+	if count == 0 || m.Author.Bot {
+		return false
+	}
+
+	quota, err := b.storage.GetAttachmentQuota(uint64(m.ChannelID))
+	if err != nil {
+		log.Printf("Error getting attachment quota: %v", err)
+		return false
+	}
+	if quota < 0 {
+		return false
+	}
+
+	// In shadow mode the message is kept, and not throttled for its embeds either. The usage is tracked
+	// apart, the quotas of the users aren't spent
+	shadow, err := b.storage.IsChannelShadow(uint64(m.ChannelID))
+	if err != nil {
+		log.Printf("Error checking shadow mode: %v", err)
+		return false
+	}
+
+	authorId := uint64(m.Author.ID)
+	var usage int
+	increaseUsage := b.storage.IncreaseAttachmentUsage
+	if shadow {
+		usage, err = b.storage.GetShadowAttachmentUsage(authorId, uint64(m.ChannelID))
+		increaseUsage = b.storage.IncreaseShadowAttachmentUsage
+	} else {
+		usage, err = b.storage.GetAttachmentUsage(authorId, uint64(m.ChannelID))
+		if errors.Is(err, sql.ErrNoRows) {
+			err = b.storage.ResetAttachmentUsage(authorId, uint64(m.ChannelID))
+		}
+	}
+	if err != nil {
+		log.Printf("Error getting attachment usage: %v", err)
+		return false
+	}
+
+	if usage+count <= quota {
+		_, err = increaseUsage(authorId, uint64(m.ChannelID), count)
+		if err != nil {
+			log.Printf("Error increasing attachment usage: %v", err)
+		}
+		return false
+	}
+
+	if shadow {
+		b.recordShadow(m, authorId, shadowAttachment, count)
+		return true
+	}
+
+	err = b.client.DeleteMessage(m.ChannelID, m.ID, api.AuditLogReason("Attachment quota exceeded"))
+	if err != nil {
+		log.Printf("Error deleting message %d: %v", m.ID, err)
+		return false
+	}
+	log.Printf("Deleted message %d in #%d for exceeding attachment quota (%d+%d/%d)", m.ID, m.ChannelID, usage, count, quota)
+
+	ch, err := b.client.CreatePrivateChannel(m.Author.ID)
+	if err != nil {
+		log.Printf("Error creating private channel: %v", err)
+		return true
+	}
+
+	user, err := b.storage.GetUser(uint64(m.Author.ID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user %d: %v", m.Author.ID, err)
+	}
+	lang := b.guildLanguage(m.GuildID, user.Locale)
+	hint := tr(lang, "attachment.hint", m.ChannelID, quota)
+	if m.Content != "" {
+		hint += "\n" + tr(lang, "attachment.original")
+	}
+	_, err = b.client.SendMessage(ch.ID, hint)
+	if err != nil {
+		log.Printf("Error sending message: %v", err)
+		return true
+	}
+
+	if m.Content != "" {
+		_, err = b.client.SendMessage(ch.ID, m.Content)
+		if err != nil {
+			log.Printf("Error sending message: %v", err)
+		}
+	}
+
+	return true
+}
+
+func (b *Bot) handleSetAttachmentQuota(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	quota, err := data.Options.Find("quota").IntValue()
+	if err != nil {
+		return err
+	}
+	if quota < 0 {
+		quota = -1
+	}
+
+	err = b.storage.SetAttachmentQuota(uint64(i.ChannelID), int(quota))
+	if err != nil {
+		return err
+	}
+
+	var msg string
+	if quota < 0 {
+		msg = tr(interactionLanguage(i), "attachment_quota.disabled")
+	} else {
+		msg = tr(interactionLanguage(i), "attachment_quota.set", quota)
+	}
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
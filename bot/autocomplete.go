@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// maxChoices is the most choices Discord accepts in an autocomplete response.
+const maxChoices = 25
+
+// autocompleteInput is what the user has typed so far in the option being autocompleted, lowercased.
+func autocompleteInput(i *gateway.InteractionCreateEvent) string {
+	_, focused := focusedOption(i.Data.(*discord.AutocompleteInteraction))
+	return strings.ToLower(strings.TrimSpace(focused.String()))
+}
+
+// matchesInput reports whether a choice named name should be suggested for the input.
+func matchesInput(name, input string) bool {
+	return input == "" || strings.Contains(strings.ToLower(name), input)
+}
+
+func (b *Bot) respondChoices(i *gateway.InteractionCreateEvent, choices api.AutocompleteChoices) error {
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.AutocompleteResult,
+		Data: &api.InteractionResponseData{Choices: choices},
+	})
+}
+
+// autocompleteRewriteHosts suggests the original domains of the link rewrites of the guild.
+func (b *Bot) autocompleteRewriteHosts(i *gateway.InteractionCreateEvent) error {
+	rewrites, err := b.storage.GetLinkRewrites(uint64(i.GuildID))
+	if err != nil {
+		return err
+	}
+
+	input := autocompleteInput(i)
+	choices := make(api.AutocompleteStringChoices, 0, len(rewrites))
+	for _, rewrite := range rewrites {
+		name := fmt.Sprintf("%s → %s", rewrite.FromHost, rewrite.ToHost)
+		if len(choices) < maxChoices && matchesInput(name, input) {
+			choices = append(choices, discord.StringChoice{Name: name, Value: rewrite.FromHost})
+		}
+	}
+	return b.respondChoices(i, choices)
+}
+
+// autocompleteSchedules suggests the throttling periods of the channel.
+func (b *Bot) autocompleteSchedules(i *gateway.InteractionCreateEvent) error {
+	schedules, err := b.storage.GetChannelSchedules(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	input := autocompleteInput(i)
+	choices := make(api.AutocompleteIntegerChoices, 0, len(schedules))
+	for _, schedule := range schedules {
+		name := fmt.Sprintf("#%d %s", schedule.ID, formatSchedule(lang, schedule))
+		if len(choices) < maxChoices && matchesInput(name, input) {
+			choices = append(choices, discord.IntegerChoice{Name: name, Value: int(schedule.ID)})
+		}
+	}
+	return b.respondChoices(i, choices)
+}
+
+// autocompleteEmbedProviders suggests the providers that already have embed costs in the guild.
+func (b *Bot) autocompleteEmbedProviders(i *gateway.InteractionCreateEvent) error {
+	costs, err := b.storage.GetEmbedCosts(uint64(i.GuildID))
+	if err != nil {
+		return err
+	}
+
+	input := autocompleteInput(i)
+	seen := make(map[string]bool)
+	choices := make(api.AutocompleteStringChoices, 0, len(costs))
+	for _, cost := range costs {
+		if cost.Provider == "" || seen[cost.Provider] {
+			continue
+		}
+		seen[cost.Provider] = true
+		if len(choices) < maxChoices && matchesInput(cost.Provider, input) {
+			choices = append(choices, discord.StringChoice{Name: cost.Provider, Value: cost.Provider})
+		}
+	}
+	return b.respondChoices(i, choices)
+}
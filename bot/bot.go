@@ -13,11 +13,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/No3371/dc_embed_throttler/clock"
 	"github.com/No3371/dc_embed_throttler/config"
 	"github.com/No3371/dc_embed_throttler/storage"
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/session/shard"
 	"github.com/diamondburned/arikawa/v3/state"
 	"github.com/diamondburned/arikawa/v3/utils/json/option"
 	"github.com/maypok86/otter"
@@ -26,217 +28,242 @@ import (
 var userMentionRegex = regexp.MustCompile(`<@\d+>`)
 
 type InteractionHandlerState struct {
+	// Command is the command the interaction is dispatched to, set by the CommandRegistry.
+	Command *Command
+	// MemberPermissions and AppPermissions are the permissions of the sender and the bot in the channel,
+	// from the payload of the interaction.
+	MemberPermissions discord.Permissions
+	AppPermissions    discord.Permissions
 }
 
 type Bot struct {
-	s                     *state.State
+	// shards are the gateway connections run by this process.
+	shards                []shard.ShardState
+	health                *shardHealth
+	client                Discord
 	storage               storage.Storage
 	config                *config.Config
+	clock                 clock.Clock
 	interactionHandler    Middleware[InteractionHandlerState]
-	recentSuppressedCache otter.Cache[uint64, struct {
-		embeds     int
-		suppressed bool
-	}]
+	registry              *CommandRegistry
+	recentSuppressedCache otter.Cache[uint64, recentSuppressed]
+	burstLimiter          *burstLimiter
+	rateLimiter           *rateLimiter
+	// shadowBurstLimiter is the burst limit of the channels in shadow mode, kept apart from the live one.
+	shadowBurstLimiter *burstLimiter
+	// deferAfter is how long handlers have to respond before the response is deferred.
+	deferAfter time.Duration
+	// deferred are the messages checked again once Discord has resolved their embeds, see LateSupressLoop.
+	deferred chan *gateway.MessageCreateEvent
+	// knownLocales are the locales of users and guilds last stored, so they are only written on changes.
+	knownLocales otter.Cache[uint64, string]
 }
 
-func (b *Bot) RespondError(i *gateway.InteractionCreateEvent, message string) error {
-	return b.s.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+type recentSuppressed struct {
+	cost       int
+	suppressed bool
+	reason     suppressReason
+}
+
+// RespondError responds the message of the key, formatted with args, in the language of the interaction.
+func (b *Bot) RespondError(i *gateway.InteractionCreateEvent, key string, args ...any) error {
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &api.InteractionResponseData{
-			Content: option.NewNullableString("❌ " + message),
+			Content: option.NewNullableString("❌ " + tr(interactionLanguage(i), key, args...)),
 			Flags:   discord.EphemeralMessage,
 		},
 	})
 }
 
-func NewBot(cfg *config.Config, store storage.Storage) (*Bot, error) {
-	c, err := otter.MustBuilder[uint64, struct {
-		embeds     int
-		suppressed bool
-	}](256).Build()
+func NewBot(cfg *config.Config, store storage.Storage, clk clock.Clock) (*Bot, error) {
+	m, all, err := newShardManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := selectShards(all, cfg.ShardIDs)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newBot(cfg, store, clk, shardedClient{stateClient{shards[0].Shard.(*state.State)}, m})
+	if err != nil {
+		return nil, err
+	}
+	b.shards = shards
+	ids := make([]int, len(shards))
+	for i, sh := range shards {
+		ids[i] = sh.ShardID()
+	}
+	b.health = newShardHealth(ids)
+	return b, nil
+}
+
+// newBot creates a bot without a gateway connection, talking to Discord through the client.
+func newBot(cfg *config.Config, store storage.Storage, clk clock.Clock, client Discord) (*Bot, error) {
+	c, err := otter.MustBuilder[uint64, recentSuppressed](256).Build()
 	if err != nil {
 		return nil, err
 	}
-	return &Bot{
-		s:                     state.New("Bot " + cfg.Token),
+	locales, err := otter.MustBuilder[uint64, string](1024).Build()
+	if err != nil {
+		return nil, err
+	}
+	b := &Bot{
+		client:                replyClient{client},
 		storage:               store,
 		config:                cfg,
+		clock:                 clk,
 		recentSuppressedCache: c,
-	}, nil
+		burstLimiter:          newBurstLimiter(),
+		shadowBurstLimiter:    newBurstLimiter(),
+		rateLimiter:           newRateLimiter(),
+		deferAfter:            2 * time.Second,
+		deferred:              make(chan *gateway.MessageCreateEvent, 64),
+		knownLocales:          locales,
+	}
+	b.registry = NewCommandRegistry(b.commands()...)
+	b.registry.Use(b.rateLimitMiddleware, b.permissionsMiddleware)
+	return b, nil
 }
 
 func (b *Bot) Start(ctx context.Context) error {
-	b.s.AddHandler(b.handleMessageCreate)
-	// b.s.AddHandler(b.handleMessageEdit)
-	b.s.AddHandler(b.handleInteractionCreate)
-	b.s.AddHandler(func(m *gateway.ReadyEvent) {
-		fmt.Printf("Ready!")
-		if b.config.UpdateCommands {
-
-			// liveCmds, err := b.s.Commands(discord.AppID(b.s.Ready().Application.ID))
-			// if err != nil {
-			// 	log.Printf("Error getting commands: %v", err)
-			// }
-			// for _, cmd := range liveCmds {
-			// 	switch cmd.Name {
-			// 	case "restore_embeds":
-			// 		b.s.DeleteCommand(discord.AppID(b.s.Ready().Application.ID), cmd.ID)
-			// 	}
-			// }
-
-			perms := discord.PermissionManageChannels
-			cmds, err := b.s.BulkOverwriteCommands(discord.AppID(b.s.Ready().Application.ID), []api.CreateCommandData{
-				{
-					Name: "suppress_embeds",
-					Type: discord.MessageCommand,
-					NameLocalizations: map[discord.Language]string{
-						discord.EnglishUS:     "Suppress Embeds",
-						discord.ChineseChina:  "抑制嵌入",
-						discord.ChineseTaiwan: "抑制嵌入",
-						discord.Japanese:      "埋め込みを抑制する",
-					},
-				},
-				{
-					Name:                     "toggle_channel",
-					Description:              "開關嵌入限流",
-					Type:                     discord.ChatInputCommand,
-					DefaultMemberPermissions: &perms,
-				},
-				{
-					Name:                     "toggle_suppress_bot",
-					Description:              "開關抑制機器人訊息",
-					Type:                     discord.ChatInputCommand,
-					DefaultMemberPermissions: &perms,
-				},
-				{
-					Name:                     "reset_quota",
-					Description:              "重設個人嵌入額度",
-					Type:                     discord.ChatInputCommand,
-					DefaultMemberPermissions: &perms,
-					Options: []discord.CommandOption{
-						discord.NewUserOption(
-							"user",
-							"使用者",
-							true,
-						),
-					},
-				},
-				{
-					Name:                     "set_role_quota",
-					Description:              "設定身分組嵌入限流",
-					Type:                     discord.ChatInputCommand,
-					DefaultMemberPermissions: &perms,
-					Options: []discord.CommandOption{
-						discord.NewRoleOption(
-							"role",
-							"身分組",
-							true,
-						),
-						discord.NewIntegerOption(
-							"quota",
-							"額度",
-							true,
-						),
-						discord.NewIntegerOption(
-							"priority",
-							"優先度（高者優先採用）",
-							true,
-						),
-					},
-				},
-				{
-					Name:                     "list_role_quotas",
-					Description:              "列出所有身分組嵌入限流設定",
-					Type:                     discord.ChatInputCommand,
-					DefaultMemberPermissions: &perms,
-				},
-				{
-					Name:        "my_quota",
-					Description: "查看個人嵌入額度",
-					Type:        discord.ChatInputCommand,
-				},
-			})
-			if err != nil {
-				log.Printf("Error overwriting commands: %v", err)
-			}
-			log.Printf("Overwrote %d commands", len(cmds))
+	var handlers []any
+	if b.config.RecordPath != "" {
+		recorder, err := NewRecorder(b.config.RecordPath, b.clock)
+		if err != nil {
+			return err
 		}
-	})
+		// Added first so events are recorded before the bot handles them
+		handlers = append(handlers, recorder.handleMessageCreate, recorder.handleMessageUpdate, recorder.handleInteractionCreate)
+		go func() {
+			<-ctx.Done()
+			recorder.Close()
+		}()
+		log.Printf("Recording gateway events to %s", b.config.RecordPath)
+	}
+
+	handlers = append(handlers,
+		b.handleMessageCreate,
+		// b.handleMessageEdit,
+		b.handleMessageDelete,
+		b.handleMessageDeleteBulk,
+	)
+	// With the endpoint, the interactions are received over HTTP instead
+	if b.config.InteractionsAddr == "" {
+		handlers = append(handlers, b.handleInteractionCreate)
+	}
+	for i, sh := range b.shards {
+		b.addShardHandlers(sh, handlers...)
+		sh.Shard.(*state.State).AddIntents(gateway.IntentGuilds | gateway.IntentGuildMessages | gateway.IntentMessageContent)
+		if i > 0 {
+			continue
+		}
+		// Commands are synced once, by the first shard of this process
+		sh.Shard.(*state.State).AddHandler(func(m *gateway.ReadyEvent) {
+			if b.config.UpdateCommands {
+				for _, scope := range CommandScopes(b.config) {
+					diff, err := b.registry.Sync(b.client, m.Application.ID, scope)
+					if err != nil {
+						log.Printf("Error syncing %s commands: %v", scope, err)
+					}
+					log.Printf("Synced %s commands: %s", scope, diff)
+				}
+			}
+		})
+	}
+
+	if b.config.InteractionsAddr != "" {
+		go func() {
+			if err := b.serveInteractions(ctx); err != nil {
+				log.Printf("Error serving interactions: %v", err)
+			}
+		}()
+	}
+	statusBot.Store(b)
+	if b.config.StatusAddr != "" {
+		go func() {
+			if err := b.serveStatus(ctx); err != nil {
+				log.Printf("Error serving status: %v", err)
+			}
+		}()
+	}
 
-	b.s.AddIntents(gateway.IntentGuilds | gateway.IntentGuildMessages | gateway.IntentMessageContent)
-	ChanDeferredSuppress = make(chan *gateway.MessageCreateEvent, 64)
 	go b.LateSupressLoop()
-	return b.s.Open(ctx)
+	go b.pruneRecentURLsLoop(ctx)
+	return shard.OpenShards(ctx, b.shards)
 }
 
 func (b *Bot) handleMessageCreate(m *gateway.MessageCreateEvent) {
-	enabled, err := b.storage.IsChannelEnabled(uint64(m.ChannelID))
+	// The replies of the bot, e.g. its rewritten links, are never throttled
+	if me, err := b.client.Me(); err == nil && m.Author.ID == me.ID {
+		return
+	}
+
+	if b.TryThrottleAttachments(m) {
+		return
+	}
+
+	enabled, err := b.isChannelShadow(m.ChannelID, m.GuildID)
+	if err == nil && !enabled {
+		enabled, err = b.isChannelEnabled(m.ChannelID, m.GuildID)
+	}
 	if err != nil {
 		log.Printf("Error checking channel status: %v", err)
 		return
 	}
 
-	if !enabled && !b.config.DefaultEnabled {
+	if !enabled {
 		return
 	}
 
-	if len(m.Embeds) == 0 {
-		if len(m.MessageSnapshots) > 0 {
-			if len(m.MessageSnapshots[0].Message.Embeds) > 0 {
-				b.TrySurpress(m)
-				return
-			} else if strings.Contains(m.MessageSnapshots[0].Message.Content, "http") {
-				ChanDeferredSuppress <- m
-				log.Printf("Message snapshot of %d in #%d deferred (%d)", m.ID, m.ChannelID, len(ChanDeferredSuppress))
-				return
-			}
-			return
-		}
-		if strings.Contains(m.Content, "http") {
-			ChanDeferredSuppress <- m
-			log.Printf("Message %d in #%d deferred (%d)", m.ID, m.ChannelID, len(ChanDeferredSuppress))
-			return
-		}
-		log.Printf("Message %d in #%d has no embeds and not potential link", m.ID, m.ChannelID)
-		return
-	} else {
+	if len(allEmbeds(&m.Message)) > 0 {
 		b.TrySurpress(m)
+		return
 	}
+	if countPotentialLinks(&m.Message) > 0 {
+		b.deferred <- m
+		log.Printf("Message %d in #%d deferred (%d)", m.ID, m.ChannelID, len(b.deferred))
+		return
+	}
+	log.Printf("Message %d in #%d has no embeds and not potential link", m.ID, m.ChannelID)
 }
 
-var ChanDeferredSuppress chan *gateway.MessageCreateEvent
-
 func (b *Bot) LateSupressLoop() {
 	for {
-		mv := <-ChanDeferredSuppress
+		mv := <-b.deferred
 
-		var countHttp int64 = int64(strings.Count(mv.Content, "http"))
-		countHttp = 1 + min(countHttp, 10)
-
-		if time.Since(mv.Timestamp.Time()) < time.Duration(countHttp*int64(time.Millisecond)*250) {
-			time.Sleep(time.Duration(countHttp*int64(time.Millisecond)*250) - time.Since(mv.Timestamp.Time()))
+		if since := b.clock.Now().Sub(mv.Timestamp.Time()); since < deferDelay(&mv.Message) {
+			time.Sleep(deferDelay(&mv.Message) - since)
 		}
 
-		msg, err := b.s.Message(mv.ChannelID, mv.ID)
-		if err != nil {
-			log.Printf("Error getting message: %v", err)
-			continue
-		}
+		b.lateSuppress(mv)
+	}
+}
 
-		if len(msg.Embeds) == 0 {
-			if len(msg.MessageSnapshots) > 0 && len(msg.MessageSnapshots[0].Message.Embeds) > 0 {
-				b.TrySurpress(mv)
-				continue
-			} else {
-				log.Printf("(Deferred) Message %d has no embeds and not potential link", msg.ID)
-				continue
-			}
-		}
+// deferDelay is how long after a message is posted its embeds are expected to be resolved.
+func deferDelay(m *discord.Message) time.Duration {
+	var countHttp int64 = int64(countPotentialLinks(m))
+	countHttp = 1 + min(countHttp, 10)
+	return time.Duration(countHttp * int64(time.Millisecond) * 250)
+}
 
-		mv.Embeds = msg.Embeds
+// lateSuppress fetches the message again, after Discord has had time to resolve its embeds.
+func (b *Bot) lateSuppress(mv *gateway.MessageCreateEvent) {
+	msg, err := b.client.Message(mv.ChannelID, mv.ID)
+	if err != nil {
+		log.Printf("Error getting message: %v", err)
+		return
+	}
 
-		b.TrySurpress(mv)
+	if len(allEmbeds(msg)) == 0 && len(b.findLinkRewrites(mv)) == 0 {
+		log.Printf("(Deferred) Message %d has no embeds and not potential link", msg.ID)
+		return
 	}
+
+	mv.Embeds = msg.Embeds
+	mv.MessageSnapshots = msg.MessageSnapshots
+
+	b.TrySurpress(mv)
 }
 
 func (b *Bot) TrySurpress(m *gateway.MessageCreateEvent) {
@@ -250,20 +277,27 @@ func (b *Bot) TrySurpress(m *gateway.MessageCreateEvent) {
 		return
 	}
 
-	count := len(m.Embeds)
-	if count == 0 && len(m.MessageSnapshots) > 0 && len(m.MessageSnapshots[0].Message.Embeds) > 0 {
-		count = len(m.MessageSnapshots[0].Message.Embeds)
+	costs := b.getEmbedCosts(m.GuildID)
+	extracted := extractEmbeds(&m.Message, costs)
+	rewrites := b.findLinkRewrites(m)
+	if len(extracted.Embeds) == 0 && len(rewrites) == 0 {
+		log.Printf("Message %d only has exempted embeds (%d), ignoring", m.ID, extracted.Exempted)
+		return
 	}
 
-	ignoring := 0
-	for _, embed := range m.Embeds {
-		if embed.Provider.Name == "Tenor" && (embed.Video != nil || embed.Image != nil) {
-			ignoring++
-		}
+	cost := extracted.Cost
+	if len(rewrites) > 0 {
+		cost = rewrittenCost(rewrites, costs)
+	}
+	if cost == 0 {
+		log.Printf("Message %d in #%d only has free embeds, ignoring", m.ID, m.ChannelID)
+		return
 	}
 
-	if ignoring == count {
-		log.Printf("Message %d is a Tenor gif, ignoring", m.ID)
+	// In shadow mode the decision is recorded but nothing is done about it
+	shadow, err := b.storage.IsChannelShadow(uint64(m.ChannelID))
+	if err != nil {
+		log.Printf("Error checking shadow mode: %v", err)
 		return
 	}
 
@@ -278,7 +312,6 @@ func (b *Bot) TrySurpress(m *gateway.MessageCreateEvent) {
 
 		match = match[2:]
 		match = match[:len(match)-1]
-		var err error
 		authorId, err = strconv.ParseUint(match, 10, 64)
 		if err != nil {
 			log.Printf("Error parsing user ID: %v", err)
@@ -290,9 +323,17 @@ func (b *Bot) TrySurpress(m *gateway.MessageCreateEvent) {
 		maid = true
 	}
 
-	usage, err := b.storage.GetQuotaUsage(authorId, uint64(m.ChannelID))
-	if errors.Is(err, sql.ErrNoRows) {
-		err = b.storage.ResetQuotaUsage(authorId, uint64(m.ChannelID))
+	// Shadow mode tracks what would have been used apart, the quotas of the users aren't spent
+	var usage int
+	increaseUsage, burstLimiter := b.storage.IncreaseQuotaUsage, b.burstLimiter
+	if shadow {
+		usage, err = b.storage.GetShadowQuotaUsage(authorId, uint64(m.ChannelID))
+		increaseUsage, burstLimiter = b.storage.IncreaseShadowQuotaUsage, b.shadowBurstLimiter
+	} else {
+		usage, err = b.storage.GetQuotaUsage(authorId, uint64(m.ChannelID))
+		if errors.Is(err, sql.ErrNoRows) {
+			err = b.storage.ResetQuotaUsage(authorId, uint64(m.ChannelID))
+		}
 	}
 	if err != nil {
 		log.Printf("Error getting quota usage: %v", err)
@@ -317,23 +358,56 @@ func (b *Bot) TrySurpress(m *gateway.MessageCreateEvent) {
 	if b.recentSuppressedCache.Has(suppressedId) {
 		log.Printf("Message %d in #%d has been suppressed recently", suppressedId, m.ChannelID)
 		cache, _ := b.recentSuppressedCache.Get(suppressedId)
-		if maid && cache.suppressed {
-			b.Suppress(&m.Message) // also suppress maid's message anyway
+		if maid && cache.suppressed && !shadow {
+			b.Suppress(&m.Message, cache.reason) // also suppress maid's message anyway
 		}
 		return
 	}
 
+	if !maid && !m.Author.Bot {
+		if first, ok := b.findDuplicate(m); ok {
+			if shadow {
+				b.recordShadow(m, authorId, reasonDuplicate.String(), 0)
+			} else {
+				err = b.suppressDuplicate(&m.Message, first)
+				if err != nil {
+					log.Printf("Error suppressing repost: %v", err)
+					return
+				}
+			}
+			b.recentSuppressedCache.Set(suppressedId, recentSuppressed{
+				cost:       0,
+				suppressed: true,
+				reason:     reasonDuplicate,
+			})
+			return
+		}
+	}
+
+	burstLimit, burstPeriod, err := b.storage.GetBurstLimit(uint64(m.ChannelID))
+	if err != nil {
+		log.Printf("Error getting burst limit: %v", err)
+	}
+	// The channel burst limit is evaluated before the user's quota
+	burstAllowed := burstLimiter.Allow(m.ChannelID, burstLimit, burstPeriod, cost, b.clock.Now())
+	reason := reasonQuota
+	if !burstAllowed {
+		reason = reasonBurst
+	}
+
 	log.Printf("Processing message %d in #%d", m.ID, m.ChannelID)
-	if usage+count <= quota {
-		_, err = b.storage.IncreaseQuotaUsage(authorId, uint64(m.ChannelID), count)
+	if burstAllowed && usage+cost <= quota {
+		_, err = increaseUsage(authorId, uint64(m.ChannelID), cost)
 		if err != nil {
 			log.Printf("Error increasing quota usage: %v", err)
 		}
-		b.recentSuppressedCache.Set(suppressedId, struct {
-			embeds     int
-			suppressed bool
-		}{
-			embeds:     count,
+		if shadow {
+			b.recordShadow(m, authorId, shadowAllow, cost)
+		} else if len(rewrites) > 0 {
+			b.postRewrittenLinks(&m.Message, rewrites)
+		}
+		b.recentSuppressedCache.Set(suppressedId, recentSuppressed{
+			cost:       cost,
 			suppressed: false,
 		})
 	} else {
@@ -348,39 +422,75 @@ func (b *Bot) TrySurpress(m *gateway.MessageCreateEvent) {
 			}
 		}
 
-		err = b.Suppress(&m.Message)
-		if err != nil {
-			log.Printf("Error suppressing embeds: %v", err)
-			return
+		if burstAllowed {
+			burstLimiter.Refund(m.ChannelID, cost)
+		}
+
+		if shadow {
+			b.recordShadow(m, authorId, reason.String(), cost)
+		} else {
+			err = b.Suppress(&m.Message, reason)
+			if err != nil {
+				log.Printf("Error suppressing embeds: %v", err)
+				return
+			}
+			b.postLinkSummary(&m.Message, extracted.Embeds)
 		}
 
-		b.recentSuppressedCache.Set(suppressedId, struct {
-			embeds     int
-			suppressed bool
-		}{
-			embeds:     count,
+		b.recentSuppressedCache.Set(suppressedId, recentSuppressed{
+			cost:       cost,
 			suppressed: true,
+			reason:     reason,
 		})
 	}
 }
 
-func (b *Bot) Suppress(m *discord.Message) (err error) {
+// suppressEmbeds only sets the flag, see Suppress for the full treatment.
+func (b *Bot) suppressEmbeds(m *discord.Message) error {
+	flags := m.Flags | discord.SuppressEmbeds
+	_, err := b.client.EditMessageComplex(m.ChannelID, m.ID, api.EditMessageData{
+		Flags: &flags,
+	})
+	return err
+}
+
+type suppressReason int
+
+const (
+	// reasonQuota is used when the author has run out of quota.
+	reasonQuota suppressReason = iota
+	// reasonBurst is used when the channel has reached its burst limit.
+	reasonBurst
+	// reasonDuplicate is used when the links have been posted recently.
+	reasonDuplicate
+)
+
+func (r suppressReason) String() string {
+	switch r {
+	case reasonQuota:
+		return "quota"
+	case reasonBurst:
+		return "burst"
+	case reasonDuplicate:
+		return "duplicate"
+	default:
+		return "unknown"
+	}
+}
+
+func (b *Bot) Suppress(m *discord.Message, reason suppressReason) (err error) {
 	if m.Flags&discord.SuppressEmbeds != 0 {
 		return
 	}
 
-	flags := m.Flags | discord.SuppressEmbeds
-	// Suppress embeds for the message
-	_, err = b.s.EditMessageComplex(m.ChannelID, m.ID, api.EditMessageData{
-		Flags: &flags,
-	})
+	err = b.suppressEmbeds(m)
 	if err != nil {
 		log.Printf("Error suppressing embeds for %d: %v", m.ID, err)
 	}
 
-	log.Printf("Suppressing embeds for %d in #%d", m.ID, m.ChannelID)
+	log.Printf("Suppressing embeds for %d in #%d (%s)", m.ID, m.ChannelID, reason)
 
-	err = b.s.React(m.ChannelID, m.ID, discord.NewAPIEmoji(0, "🈚"))
+	err = b.client.React(m.ChannelID, m.ID, discord.NewAPIEmoji(0, "🈚"))
 	if err != nil {
 		log.Printf("Error reacting to message: %v", err)
 	}
@@ -389,50 +499,66 @@ func (b *Bot) Suppress(m *discord.Message) (err error) {
 		return
 	}
 
-	user, err := b.storage.GetUser(uint64(m.Author.ID))
-	if errors.Is(err, sql.ErrNoRows) {
-		err = nil
-	}
+	cooldown, due, err := b.nextHint(m.Author.ID)
 	if err != nil {
 		log.Printf("Error getting last hint at for user %d: %v", m.Author.ID, err)
 		return
 	}
 
-	if time.Now().After(user.NextHintAt) {
-		ch, err := b.s.CreatePrivateChannel(m.Author.ID)
+	if due && b.sendHint(m, reason, cooldown) {
+		err = b.storage.SetNextHintAt(uint64(m.Author.ID), b.clock.Now().Add(cooldown))
 		if err != nil {
-			log.Printf("Error creating private channel: %v", err)
+			log.Printf("Error setting next hint at: %v", err)
 		}
 
-		factor := int(math.Pow(2, float64(min(5, user.Hinted))))
-		var cooldown = 24 * factor
+		log.Printf("Sent %s hint to %d", reason, m.Author.ID)
+	}
 
-		_, err = b.s.SendMessage(ch.ID, fmt.Sprintf("<#%d>頻道已啟用嵌入限流，您方才發送的訊息已抑制嵌入。\n若有需要回收嵌入額度請右鍵訊息 > APP 選單中選擇「抑制嵌入」\n-# - 每人每天有限量嵌入額度\n-# - %d 小時內不會再收到此提示", m.ChannelID, cooldown))
-		if err != nil {
-			log.Printf("Error sending message: %v", err)
-		}
+	return nil
+}
 
-		err = b.storage.SetNextHintAt(uint64(m.Author.ID), time.Now().Add(time.Duration(cooldown)*time.Hour))
-		if err != nil {
-			log.Printf("Error setting next hint at: %v", err)
-		}
+// hintCooldown doubles with every hint the user has received, from 24 hours up to 32 days.
+func hintCooldown(hinted int) time.Duration {
+	factor := int(math.Pow(2, float64(min(5, hinted))))
+	return time.Duration(24*factor) * time.Hour
+}
 
-		log.Printf("Sent hint to %d", m.Author.ID)
+// nextHint reports whether the user is due for a hint, and the cooldown to apply after sending it.
+func (b *Bot) nextHint(userID discord.UserID) (cooldown time.Duration, due bool, err error) {
+	user, err := b.storage.GetUser(uint64(userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	if err != nil {
+		return 0, false, err
 	}
 
-	return nil
+	return hintCooldown(user.Hinted), b.clock.Now().After(user.NextHintAt), nil
+}
+
+// reclaimWindow is how long after posting a message its author can suppress the embeds to get the quota back.
+const reclaimWindow = time.Minute
+
+func (b *Bot) canReclaim(msg *discord.Message) bool {
+	return b.clock.Now().Sub(msg.Timestamp.Time()) <= reclaimWindow
 }
 
 type InteractionTokenCache struct {
 	CreatedAt time.Time
 	IType     discord.InteractionDataType
 	Id        string
+	AppID     discord.AppID
+	// reply is how the interaction has been responded to so far, see replyClient.
+	reply *interactionReply
 }
 
-var interactionTokenCache *otter.Cache[string, InteractionTokenCache]
+// interactionTokenTTL is how long an interaction is tracked, unless its response is deferred.
+const interactionTokenTTL = time.Second * 5
+
+var interactionTokenCache *otter.CacheWithVariableTTL[string, InteractionTokenCache]
 
 func init() {
-	c, err := otter.MustBuilder[string, InteractionTokenCache](128).WithTTL(time.Second * 5).DeletionListener(func(key string, value InteractionTokenCache, cause otter.DeletionCause) {
+	c, err := otter.MustBuilder[string, InteractionTokenCache](128).WithVariableTTL().DeletionListener(func(key string, value InteractionTokenCache, cause otter.DeletionCause) {
 		switch cause {
 		case otter.Expired:
 			log.Printf("interaction %s expired after %s", key, time.Since(value.CreatedAt))
@@ -444,7 +570,13 @@ func init() {
 	interactionTokenCache = &c
 }
 
-func (b *Bot) handleInteractionCreate(e *gateway.InteractionCreateEvent) {
+func (b *Bot) handleInteractionCreate(e *interactionCreateEvent) {
+	b.handleInteraction(e, &interactionReply{})
+}
+
+// handleInteraction runs the interaction through the middlewares to its handler, which respond through reply.
+func (b *Bot) handleInteraction(ev *interactionCreateEvent, reply *interactionReply) {
+	e := &ev.InteractionCreateEvent
 	if e.Member == nil {
 		return
 	}
@@ -461,57 +593,50 @@ func (b *Bot) handleInteractionCreate(e *gateway.InteractionCreateEvent) {
 		}
 	}()
 
+	b.rememberLocales(e)
+
 	var name string = "?"
 
 	itCache := InteractionTokenCache{
 		CreatedAt: time.Now(),
 		IType:     e.Data.InteractionType(),
+		AppID:     e.AppID,
+		reply:     reply,
 	}
 	switch data := e.Data.(type) {
 	case *discord.CommandInteraction:
 		itCache.Id = data.Name
 		name = data.Name
-	case *discord.ButtonInteraction:
-		itCache.Id = string(data.CustomID)
-		name = string(data.CustomID)
-	case *discord.StringSelectInteraction:
-		itCache.Id = string(data.CustomID)
-		name = string(data.CustomID)
+	case discord.ComponentInteraction:
+		itCache.Id = string(data.ID())
+		name = string(data.ID())
 	case *discord.ModalInteraction:
 		itCache.Id = string(data.CustomID)
 		name = string(data.CustomID)
+	case *discord.AutocompleteInteraction:
+		itCache.Id = data.Name
+		name = data.Name
 	}
-	interactionTokenCache.Set(e.Token, itCache)
+	interactionTokenCache.Set(e.Token, itCache, interactionTokenTTL)
 
-	state := InteractionHandlerState{}
+	state := InteractionHandlerState{
+		MemberPermissions: ev.MemberPermissions,
+		AppPermissions:    ev.AppPermissions,
+	}
 	handler := func(e *gateway.InteractionCreateEvent, state *InteractionHandlerState, next ...Middleware[InteractionHandlerState]) error {
 		var err error
 		switch e.Data.InteractionType() {
 		case discord.PingInteractionType:
 		case discord.CommandInteractionType:
-			switch e.Data.(*discord.CommandInteraction).Name {
-			case "suppress_embeds":
-				err = b.handleSuppressEmbeds(e)
-			case "toggle_channel":
-				err = b.handleToggleChannel(e)
-			case "set_role_quota":
-				err = b.handleSetRoleQuota(e)
-			case "reset_quota":
-				err = b.handleResetQuota(e)
-			case "toggle_suppress_bot":
-				err = b.handleToggleSuppressBot(e)
-			case "list_role_quotas":
-				err = b.handleListRoleQuotas(e)
-			case "my_quota":
-				err = b.handleMyQuota(e)
-			}
-		case discord.ComponentInteractionType:
+			err = b.registry.Dispatch(e, state)
+		case discord.ComponentInteractionType, discord.ModalInteractionType:
+			err = b.registry.DispatchComponent(e, state)
 		case discord.AutocompleteInteractionType:
-		case discord.ModalInteractionType:
+			err = b.registry.DispatchAutocomplete(e, state)
 		}
 		return err
 	}
-	err = PanicRecoveryMiddleware(e, &state, LoggingMiddleware, handler)
+	err = PanicRecoveryMiddleware(e, &state, LoggingMiddleware, b.deferMiddleware, handler)
 
 	if err != nil {
 		log.Printf("Error handling interaction (%s): %v", name, err)
@@ -525,35 +650,36 @@ func (b *Bot) handleSuppressEmbeds(e *gateway.InteractionCreateEvent) error {
 
 	msg, ok := data.Resolved.Messages[data.TargetMessageID()]
 	if !ok {
-		return b.RespondError(e, "Message not found")
+		return b.RespondError(e, "error.message_not_found")
 	}
 
 	if msg.Author.ID != sender {
 		if !(msg.Author.ID == 1290664871993806932 && strings.HasPrefix(msg.Content, fmt.Sprintf("<@%d>", sender))) {
-			return b.RespondError(e, "你不是此訊息的作者")
+			return b.RespondError(e, "error.not_author")
 		}
 	}
 	if msg.Flags&discord.SuppressEmbeds > 0 {
-		return b.RespondError(e, "此訊息已抑制嵌入")
+		return b.RespondError(e, "error.already_suppressed")
 	}
 
-	if time.Since(msg.Timestamp.Time()) > time.Minute {
-		return b.RespondError(e, "無法在一分鐘後回收額度")
+	if !b.canReclaim(&msg) {
+		return b.RespondError(e, "error.reclaim_expired")
 	}
 
-	if len(msg.Embeds) == 0 {
-		return b.RespondError(e, "Bot 端從 Discord 端取得的此訊息並未包含任何嵌入項目")
+	extracted := extractEmbeds(&msg, b.getEmbedCosts(e.GuildID))
+	if len(extracted.Embeds) == 0 {
+		return b.RespondError(e, "error.no_embeds")
 	}
 
 	flags := msg.Flags | discord.SuppressEmbeds
 	// Suppress embeds for the message
-	_, err := b.s.EditMessageComplex(msg.ChannelID, msg.ID, api.EditMessageData{
+	_, err := b.client.EditMessageComplex(msg.ChannelID, msg.ID, api.EditMessageData{
 		Flags: &flags,
 	})
 
 	if err != nil {
 		log.Printf("Error editing message: %v", err)
-		return b.RespondError(e, "Discord 端發生錯誤")
+		return b.RespondError(e, "error.discord")
 	}
 
 	err = b.storage.TryResetQuotaOnNextDay(uint64(sender), uint64(channelId))
@@ -561,7 +687,7 @@ func (b *Bot) handleSuppressEmbeds(e *gateway.InteractionCreateEvent) error {
 		log.Printf("Error resetting quota usage: %v", err)
 	}
 
-	usage, err := b.storage.DecreaseQuotaUsage(uint64(sender), uint64(channelId), len(msg.Embeds))
+	usage, err := b.storage.DecreaseQuotaUsage(uint64(sender), uint64(channelId), extracted.Cost)
 	if err != nil {
 		log.Printf("Error decrementing quota usage: %v", err)
 	}
@@ -580,10 +706,10 @@ func (b *Bot) handleSuppressEmbeds(e *gateway.InteractionCreateEvent) error {
 	}
 
 	respd := api.InteractionResponseData{
-		Content: option.NewNullableString(fmt.Sprintf("-# ✅ 於此頻道展開額度：%d/%d", quota-usage, quota)),
+		Content: option.NewNullableString(tr(interactionLanguage(e), "quota.remaining", quota-usage, quota)),
 		Flags:   discord.EphemeralMessage,
 	}
-	err = b.s.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
+	err = b.client.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &respd,
 	})
@@ -595,43 +721,24 @@ func (b *Bot) handleSuppressEmbeds(e *gateway.InteractionCreateEvent) error {
 }
 
 func (b *Bot) handleToggleChannel(i *gateway.InteractionCreateEvent) error {
-	// Check if user has manage channel permission
-	perms, err := b.s.Permissions(i.ChannelID, i.Member.User.ID)
-	if err != nil {
-		return b.RespondError(i, "Error checking permissions")
-	}
-
-	if !perms.Has(discord.PermissionManageChannels) {
-		return b.RespondError(i, "You need the Manage Channels permission to use this command")
-	}
-
-	myPerms, err := b.s.Permissions(i.ChannelID, b.s.Ready().User.ID)
-	if err != nil {
-		return b.RespondError(i, "Error checking permissions")
-	}
-
-	if !myPerms.Has(discord.PermissionViewChannel) {
-		return b.RespondError(i, "Please check if I have permission to view this channel")
-	}
-
 	enabled, err := b.storage.IsChannelEnabled(uint64(i.ChannelID))
 	if err != nil {
-		return b.RespondError(i, "Error checking channel status")
+		return b.RespondError(i, "error.check_channel")
 	}
 
 	err = b.storage.SetChannelEnabled(uint64(i.ChannelID), !enabled)
 	if err != nil {
-		return b.RespondError(i, "Error toggling channel status")
+		return b.RespondError(i, "error.toggle_channel")
 	}
 
-	status := "disabled"
+	key := "toggle_channel.disabled"
 	if !enabled {
-		status = "enabled"
+		key = "toggle_channel.enabled"
 	}
-	return b.s.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &api.InteractionResponseData{
-			Content: option.NewNullableString(fmt.Sprintf("Embed throttling has been %s for this channel", status)),
+			Content: option.NewNullableString(tr(interactionLanguage(i), key)),
 			Flags:   discord.EphemeralMessage,
 		},
 	})
@@ -651,17 +758,15 @@ func (b *Bot) handleToggleSuppressBot(i *gateway.InteractionCreateEvent) error {
 		return err
 	}
 
-	var msg string
+	key := "toggle_suppress_bot.disabled"
 	if !channelSuppressingBot {
-		msg = fmt.Sprintf("-# ✅ 此頻道已**啟用**抑制機器人訊息嵌入")
-	} else {
-		msg = fmt.Sprintf("-# ✅ 此頻道已**停用**抑制機器人訊息嵌入")
+		key = "toggle_suppress_bot.enabled"
 	}
 	respd := api.InteractionResponseData{
-		Content: option.NewNullableString(msg),
+		Content: option.NewNullableString(tr(interactionLanguage(i), key)),
 		Flags:   discord.EphemeralMessage,
 	}
-	return b.s.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &respd,
 	})
@@ -683,6 +788,9 @@ func (b *Bot) handleSetRoleQuota(i *gateway.InteractionCreateEvent) error {
 	if err != nil {
 		return err
 	}
+	if quota < 0 || priority < 0 {
+		return b.RespondError(i, "error.negative_role_quota")
+	}
 
 	err = b.storage.ConfigureRoleQuota(uint64(i.ChannelID), uint64(roleID), int(quota), int(priority))
 	if err != nil {
@@ -690,10 +798,10 @@ func (b *Bot) handleSetRoleQuota(i *gateway.InteractionCreateEvent) error {
 	}
 
 	respd := api.InteractionResponseData{
-		Content: option.NewNullableString(fmt.Sprintf("-# ✅ 身分組 <@&%d> 的嵌入限流額度已設定為 %d", roleID, quota)),
+		Content: option.NewNullableString(tr(interactionLanguage(i), "role_quota.set", roleID, quota)),
 		Flags:   discord.EphemeralMessage,
 	}
-	return b.s.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &respd,
 	})
@@ -711,11 +819,16 @@ func (b *Bot) handleResetQuota(i *gateway.InteractionCreateEvent) error {
 		return err
 	}
 
+	err = b.storage.ResetAttachmentUsage(uint64(userID), uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+
 	respd := api.InteractionResponseData{
-		Content: option.NewNullableString(fmt.Sprintf("-# ✅ 已重設 <@%d> 的嵌入額度", userID)),
+		Content: option.NewNullableString(tr(interactionLanguage(i), "quota.reset", userID)),
 		Flags:   discord.EphemeralMessage,
 	}
-	return b.s.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &respd,
 	})
@@ -727,17 +840,18 @@ func (b *Bot) handleListRoleQuotas(i *gateway.InteractionCreateEvent) error {
 		return err
 	}
 
+	lang := interactionLanguage(i)
 	sb := strings.Builder{}
-	sb.WriteString("-# 以下為此頻道所有身分組嵌入限流設定：\n")
+	sb.WriteString(tr(lang, "role_quota.list") + "\n")
 	for _, quota := range quotas {
-		sb.WriteString(fmt.Sprintf("-# - <@&%d>：%d (p%d)\n", quota.RoleID, quota.Quota, quota.Priority))
+		sb.WriteString(tr(lang, "role_quota.item", quota.RoleID, quota.Quota, quota.Priority) + "\n")
 	}
 
 	respd := api.InteractionResponseData{
 		Content: option.NewNullableString(sb.String()),
 		Flags:   discord.EphemeralMessage,
 	}
-	return b.s.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &respd,
 	})
@@ -765,13 +879,93 @@ func (b *Bot) handleMyQuota(e *gateway.InteractionCreateEvent) error {
 		log.Printf("Error getting quota by roles: %v", err)
 	}
 
+	lang := interactionLanguage(e)
+	sb := strings.Builder{}
+	sb.WriteString(tr(lang, "quota.remaining", quota-usage, quota) + "\n")
+	if attachmentQuota, err := b.storage.GetAttachmentQuota(uint64(e.ChannelID)); err == nil && attachmentQuota >= 0 {
+		attachmentUsage, err := b.storage.GetAttachmentUsage(uint64(e.Member.User.ID), uint64(e.ChannelID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error getting attachment usage: %v", err)
+		}
+		sb.WriteString(tr(lang, "quota.attachment_remaining", attachmentQuota-attachmentUsage, attachmentQuota) + "\n")
+	}
+	if costs := b.getEmbedCosts(e.GuildID); len(costs) > 0 {
+		sb.WriteString(tr(lang, "quota.costs") + "\n")
+		writeEmbedCosts(&sb, lang, costs)
+	}
+
 	respd := api.InteractionResponseData{
-		Content: option.NewNullableString(fmt.Sprintf("-# ✅ 於此頻道展開額度：%d/%d", quota-usage, quota)),
+		Content: option.NewNullableString(sb.String()),
 		Flags:   discord.EphemeralMessage,
 	}
-	err = b.s.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
+	err = b.client.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
 		Type: api.MessageInteractionWithSource,
 		Data: &respd,
 	})
 	return err
 }
+
+func (b *Bot) handleSetEmbedCost(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	embedType := data.Options.Find("type").String()
+	provider := data.Options.Find("provider").String()
+	cost, err := data.Options.Find("cost").IntValue()
+	if err != nil {
+		return err
+	}
+
+	err = b.storage.SetEmbedCost(uint64(i.GuildID), embedType, provider, int(cost))
+	if err != nil {
+		return err
+	}
+
+	target := embedCostTarget(embedType, provider)
+	var msg string
+	if cost < 0 {
+		msg = tr(interactionLanguage(i), "embed_cost.removed", target)
+	} else {
+		msg = tr(interactionLanguage(i), "embed_cost.set", target, cost)
+	}
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleListEmbedCosts(i *gateway.InteractionCreateEvent) error {
+	costs, err := b.storage.GetEmbedCosts(uint64(i.GuildID))
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	sb := strings.Builder{}
+	sb.WriteString(tr(lang, "embed_cost.list") + "\n")
+	writeEmbedCosts(&sb, lang, costs)
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(sb.String()),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func embedCostTarget(embedType, provider string) string {
+	if provider == "" {
+		return embedType
+	}
+	return fmt.Sprintf("%s (%s)", embedType, provider)
+}
+
+func writeEmbedCosts(sb *strings.Builder, lang discord.Language, costs []storage.EmbedCost) {
+	for _, cost := range costs {
+		sb.WriteString(tr(lang, "embed_cost.item", embedCostTarget(cost.EmbedType, cost.Provider), cost.Cost) + "\n")
+	}
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// tokenBucket holds up to capacity tokens and refills capacity tokens per period.
+type tokenBucket struct {
+	capacity float64
+	period   time.Duration
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, period time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		period:   period,
+		tokens:   float64(capacity),
+		last:     now,
+	}
+}
+
+func (t *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(t.last); elapsed > 0 {
+		t.tokens = min(t.capacity, t.tokens+t.capacity*float64(elapsed)/float64(t.period))
+		t.last = now
+	}
+}
+
+// take removes n tokens if there are enough of them.
+func (t *tokenBucket) take(n int, now time.Time) bool {
+	t.refill(now)
+	if t.tokens < float64(n) {
+		return false
+	}
+	t.tokens -= float64(n)
+	return true
+}
+
+func (t *tokenBucket) give(n int) {
+	t.tokens = min(t.capacity, t.tokens+float64(n))
+}
+
+// burstLimiter limits how many embeds are posted in a channel across all users.
+type burstLimiter struct {
+	mu      sync.Mutex
+	buckets map[discord.ChannelID]*tokenBucket
+}
+
+func newBurstLimiter() *burstLimiter {
+	return &burstLimiter{
+		buckets: make(map[discord.ChannelID]*tokenBucket),
+	}
+}
+
+// Allow takes cost tokens from the channel's bucket, the bucket is reset whenever the channel's limit is changed.
+func (l *burstLimiter) Allow(channelID discord.ChannelID, limit int, period time.Duration, cost int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit <= 0 || period <= 0 {
+		delete(l.buckets, channelID)
+		return true
+	}
+
+	bucket, ok := l.buckets[channelID]
+	if !ok || bucket.capacity != float64(limit) || bucket.period != period {
+		bucket = newTokenBucket(limit, period, now)
+		l.buckets[channelID] = bucket
+	}
+	return bucket.take(cost, now)
+}
+
+// Refund gives back tokens taken for a message that ended up suppressed for another reason.
+func (l *burstLimiter) Refund(channelID discord.ChannelID, cost int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bucket, ok := l.buckets[channelID]; ok {
+		bucket.give(cost)
+	}
+}
+
+func (b *Bot) handleSetBurstLimit(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	limit, err := data.Options.Find("embeds").IntValue()
+	if err != nil {
+		return err
+	}
+	seconds, err := data.Options.Find("seconds").IntValue()
+	if err != nil {
+		return err
+	}
+	if limit <= 0 || seconds <= 0 {
+		limit, seconds = 0, 0
+	}
+
+	err = b.storage.SetBurstLimit(uint64(i.ChannelID), int(limit), time.Duration(seconds)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	var msg string
+	if limit == 0 {
+		msg = tr(interactionLanguage(i), "burst.disabled")
+	} else {
+		msg = tr(interactionLanguage(i), "burst.set", seconds, limit)
+	}
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurstLimiter(t *testing.T) {
+	l := newBurstLimiter()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow(1, 3, time.Minute, 1, now) {
+			t.Fatalf("Expected embed %d to be allowed", i)
+		}
+	}
+	if l.Allow(1, 3, time.Minute, 1, now) {
+		t.Fatalf("Expected the 4th embed to be limited")
+	}
+	if !l.Allow(2, 3, time.Minute, 1, now) {
+		t.Fatalf("Expected channels to be limited separately")
+	}
+
+	// One token is refilled every 20 seconds
+	if !l.Allow(1, 3, time.Minute, 1, now.Add(20*time.Second)) {
+		t.Fatalf("Expected a token to be refilled")
+	}
+	if l.Allow(1, 3, time.Minute, 1, now.Add(20*time.Second)) {
+		t.Fatalf("Expected only one token to be refilled")
+	}
+
+	l.Refund(1, 1)
+	if !l.Allow(1, 3, time.Minute, 1, now.Add(20*time.Second)) {
+		t.Fatalf("Expected the refunded token to be available")
+	}
+
+	if l.Allow(1, 3, time.Minute, 4, now.Add(time.Hour)) {
+		t.Fatalf("Expected cost over the capacity to be limited")
+	}
+	if !l.Allow(1, 5, time.Minute, 4, now.Add(time.Hour)) {
+		t.Fatalf("Expected the bucket to be reset after the limit is changed")
+	}
+	if !l.Allow(1, 0, 0, 100, now.Add(time.Hour)) {
+		t.Fatalf("Expected no limit when disabled")
+	}
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// newStorageBot creates a bot without a Discord connection, for testing logic that only touches storage.
+func newStorageBot(t *testing.T, clk clock.Clock) *Bot {
+	t.Helper()
+	store, err := storage.NewSQLiteStorage("file:"+t.Name()+"?mode=memory&cache=shared", clk)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return &Bot{
+		storage:      store,
+		config:       &config.Config{DefaultQuota: 3},
+		clock:        clk,
+		burstLimiter: newBurstLimiter(),
+	}
+}
+
+func TestHintCooldownEscalation(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := newStorageBot(t, clk)
+	userID := discord.UserID(1)
+
+	expected := []time.Duration{24, 48, 96, 192, 384, 768, 768}
+	for i, hours := range expected {
+		cooldown, due, err := b.nextHint(userID)
+		if err != nil {
+			t.Fatalf("Failed to get next hint: %v", err)
+		}
+		if !due {
+			t.Fatalf("Expected hint %d to be due at %v", i, clk.Now())
+		}
+		if cooldown != hours*time.Hour {
+			t.Fatalf("Expected cooldown of hint %d to be %dh, got %v", i, hours, cooldown)
+		}
+
+		// What Suppress does after sending the hint
+		if err := b.storage.SetNextHintAt(uint64(userID), clk.Now().Add(cooldown)); err != nil {
+			t.Fatalf("Failed to set next hint at: %v", err)
+		}
+
+		clk.Advance(cooldown - time.Minute)
+		if _, due, _ := b.nextHint(userID); due {
+			t.Fatalf("Expected hint %d to be cooling down at %v", i+1, clk.Now())
+		}
+		clk.Advance(2 * time.Minute)
+	}
+}
+
+func TestReclaimWindow(t *testing.T) {
+	sentAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(sentAt)
+	b := newStorageBot(t, clk)
+	msg := &discord.Message{Timestamp: discord.NewTimestamp(sentAt)}
+
+	for _, tt := range []struct {
+		after    time.Duration
+		expected bool
+	}{
+		{0, true},
+		{59 * time.Second, true},
+		{reclaimWindow, true},
+		{reclaimWindow + time.Second, false},
+		{time.Hour, false},
+	} {
+		clk.Set(sentAt.Add(tt.after))
+		if reclaimable := b.canReclaim(msg); reclaimable != tt.expected {
+			t.Errorf("Expected reclaimable %v after %v, got %v", tt.expected, tt.after, reclaimable)
+		}
+	}
+}
+
+func TestIsChannelEnabledFollowsSchedule(t *testing.T) {
+	// 2025-01-06 is a Monday, 12:00 in Taipei
+	clk := clock.NewFake(time.Date(2025, 1, 6, 4, 0, 0, 0, time.UTC))
+	b := newStorageBot(t, clk)
+	channelID := discord.ChannelID(1)
+	guildID := discord.GuildID(2)
+
+	if err := b.storage.SetChannelEnabled(uint64(channelID), true); err != nil {
+		t.Fatalf("Failed to enable channel: %v", err)
+	}
+	_, err := b.storage.AddChannelSchedule(uint64(channelID), storage.Schedule{Weekday: -1, StartMinute: 18 * 60, EndMinute: 2 * 60})
+	if err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+
+	expectEnabled := func(expected bool) {
+		t.Helper()
+		enabled, err := b.isChannelEnabled(channelID, guildID)
+		if err != nil {
+			t.Fatalf("Failed to check channel status: %v", err)
+		}
+		if enabled != expected {
+			t.Fatalf("Expected enabled %v at %v, got %v", expected, clk.Now(), enabled)
+		}
+	}
+
+	expectEnabled(false)
+	clk.Advance(7 * time.Hour) // 19:00
+	expectEnabled(true)
+	clk.Advance(6 * time.Hour) // 01:00
+	expectEnabled(true)
+	clk.Advance(time.Hour) // 02:00
+	expectEnabled(false)
+
+	// 18:00 in Tokyo is 17:00 in Taipei
+	if err := b.storage.SetGuildTimezone(uint64(guildID), "Asia/Tokyo"); err != nil {
+		t.Fatalf("Failed to set timezone: %v", err)
+	}
+	clk.Set(time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC))
+	expectEnabled(true)
+}
@@ -0,0 +1,433 @@
+package bot
+
+import (
+	"strconv"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// commands are the application commands of the bot.
+func (b *Bot) commands() []*Command {
+	perms := discord.PermissionManageChannels
+	guildPerms := discord.PermissionManageGuild
+	// throttlePerms are what the bot needs to throttle the messages of a channel
+	throttlePerms := discord.PermissionViewChannel | discord.PermissionManageMessages | discord.PermissionAddReactions
+	return []*Command{
+		{
+			Data: api.CreateCommandData{
+				Name: "suppress_embeds",
+				Type: discord.MessageCommand,
+			},
+			BotPermissions: discord.PermissionManageMessages,
+			Handler:        b.handleSuppressEmbeds,
+		},
+		{
+			Data:           api.CreateCommandData{Name: "toggle_channel"},
+			Permissions:    perms,
+			BotPermissions: throttlePerms,
+			Handler:        b.handleToggleChannel,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "toggle_suppress_bot"},
+			Permissions: perms,
+			Handler:     b.handleToggleSuppressBot,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "toggle_link_summary"},
+			Permissions: perms,
+			Handler:     b.handleToggleLinkSummary,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "embed_hints",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName: "mode",
+						Required:   true,
+						Choices: []discord.StringChoice{
+							{Value: hintModeOn},
+							{Value: hintModeOff},
+							{Value: hintModeChannel},
+						},
+					},
+				},
+			},
+			Handler: b.handleEmbedHints,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_hint_delivery",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName: "delivery",
+						Required:   true,
+						Choices: []discord.StringChoice{
+							{Value: hintDeliveryDM},
+							{Value: hintDeliveryChannel},
+						},
+					},
+				},
+			},
+			Permissions: guildPerms,
+			Handler:     b.handleSetHintDelivery,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_hint_template",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName: "reason",
+						Required:   true,
+						Choices: []discord.StringChoice{
+							{Value: reasonQuota.String()},
+							{Value: reasonBurst.String()},
+						},
+					},
+					&discord.StringOption{OptionName: "template"},
+				},
+			},
+			Permissions: guildPerms,
+			Handler:     b.handleSetHintTemplate,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "toggle_shadow"},
+			Permissions: perms,
+			Handler:     b.handleToggleShadow,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "shadow_report"},
+			Permissions: perms,
+			Handler:     b.handleShadowReport,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_duplicate_window",
+				Options: []discord.CommandOption{
+					&discord.IntegerOption{OptionName: "minutes", Required: true},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleSetDuplicateWindow,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_burst_limit",
+				Options: []discord.CommandOption{
+					&discord.IntegerOption{OptionName: "embeds", Required: true},
+					&discord.IntegerOption{OptionName: "seconds", Required: true},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleSetBurstLimit,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "schedule_throttling"},
+			Permissions: perms,
+			Subcommands: []Subcommand{
+				{
+					Option: discord.SubcommandOption{
+						OptionName: "add",
+						Options: []discord.CommandOptionValue{
+							&discord.IntegerOption{
+								OptionName: "weekday",
+								Required:   true,
+								Choices:    weekdayChoices(),
+							},
+							&discord.StringOption{OptionName: "start", Required: true},
+							&discord.StringOption{OptionName: "end", Required: true},
+						},
+					},
+					Handler: b.handleAddSchedule,
+				},
+				{
+					Option: discord.SubcommandOption{
+						OptionName: "remove",
+						Options: []discord.CommandOptionValue{
+							&discord.IntegerOption{OptionName: "id", Required: true, Autocomplete: true},
+						},
+					},
+					Handler: b.handleRemoveSchedule,
+				},
+				{
+					Option:  discord.SubcommandOption{OptionName: "list"},
+					Handler: b.handleListSchedules,
+				},
+			},
+			Autocomplete: map[string]CommandHandler{
+				"remove.id": b.autocompleteSchedules,
+			},
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_timezone",
+				Options: []discord.CommandOption{
+					&discord.StringOption{OptionName: "timezone", Required: true},
+				},
+			},
+			Permissions: guildPerms,
+			Handler:     b.handleSetTimezone,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "reset_quota",
+				Options: []discord.CommandOption{
+					&discord.UserOption{OptionName: "user", Required: true},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleResetQuota,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_role_quota",
+				Options: []discord.CommandOption{
+					&discord.RoleOption{OptionName: "role", Required: true},
+					&discord.IntegerOption{OptionName: "quota", Required: true, Min: option.NewInt(0)},
+					&discord.IntegerOption{OptionName: "priority", Required: true, Min: option.NewInt(0)},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleSetRoleQuota,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "remove_role_quota",
+				Options: []discord.CommandOption{
+					&discord.StringOption{OptionName: "role", Required: true, Autocomplete: true},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleRemoveRoleQuota,
+			Autocomplete: map[string]CommandHandler{
+				"role": b.autocompleteRoleQuotas,
+			},
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "copy_role_quotas",
+				Options: []discord.CommandOption{
+					&discord.ChannelOption{OptionName: "from", Required: true, ChannelTypes: quotaChannelTypes},
+					&discord.ChannelOption{OptionName: "to", Required: true, ChannelTypes: quotaChannelTypes},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleCopyRoleQuotas,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "apply_role_quotas",
+				Options: []discord.CommandOption{
+					&discord.ChannelOption{OptionName: "from", Required: true, ChannelTypes: quotaChannelTypes},
+					&discord.ChannelOption{OptionName: "category", Required: true, ChannelTypes: []discord.ChannelType{discord.GuildCategory}},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleApplyRoleQuotas,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "list_role_quotas"},
+			Permissions: perms,
+			Handler:     b.handleListRoleQuotas,
+		},
+		{
+			Data:    api.CreateCommandData{Name: "my_quota"},
+			Handler: b.handleMyQuota,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_attachment_quota",
+				Options: []discord.CommandOption{
+					&discord.IntegerOption{OptionName: "quota", Required: true},
+				},
+			},
+			Permissions: perms,
+			Handler:     b.handleSetAttachmentQuota,
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_embed_cost",
+				Options: []discord.CommandOption{
+					&discord.StringOption{
+						OptionName: "type",
+						Required:   true,
+						Choices:    embedTypeChoices(),
+					},
+					&discord.IntegerOption{OptionName: "cost", Required: true},
+					&discord.StringOption{OptionName: "provider", Autocomplete: true},
+				},
+			},
+			Permissions: guildPerms,
+			Handler:     b.handleSetEmbedCost,
+			Autocomplete: map[string]CommandHandler{
+				"provider": b.autocompleteEmbedProviders,
+			},
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "set_link_rewrite",
+				Options: []discord.CommandOption{
+					&discord.StringOption{OptionName: "from", Required: true, Autocomplete: true},
+					&discord.StringOption{OptionName: "to", Required: true},
+				},
+			},
+			Permissions: guildPerms,
+			Handler:     b.handleSetLinkRewrite,
+			Autocomplete: map[string]CommandHandler{
+				"from": b.autocompleteRewriteHosts,
+			},
+		},
+		{
+			Data: api.CreateCommandData{
+				Name: "remove_link_rewrite",
+				Options: []discord.CommandOption{
+					&discord.StringOption{OptionName: "from", Required: true, Autocomplete: true},
+				},
+			},
+			Permissions: guildPerms,
+			Handler:     b.handleRemoveLinkRewrite,
+			Autocomplete: map[string]CommandHandler{
+				"from": b.autocompleteRewriteHosts,
+			},
+		},
+		{
+			Data:        api.CreateCommandData{Name: "list_link_rewrites"},
+			Permissions: guildPerms,
+			Handler:     b.handleListLinkRewrites,
+		},
+		{
+			Data:        api.CreateCommandData{Name: "list_embed_costs"},
+			Permissions: guildPerms,
+			Handler:     b.handleListEmbedCosts,
+		},
+		{
+			Data:           api.CreateCommandData{Name: settingsCommand},
+			Permissions:    perms,
+			BotPermissions: throttlePerms,
+			Subcommands: []Subcommand{
+				{
+					Option:  discord.SubcommandOption{OptionName: "settings"},
+					Handler: b.handleEmbedsSettings,
+				},
+			},
+			Components: map[string]CommandHandler{
+				"toggle":           b.handleSettingsToggle,
+				"attachment":       b.handleSettingsAttachment,
+				"attachment_quota": b.handleSettingsAttachmentQuota,
+				"role":             b.handleSettingsRole,
+				"role_quota":       b.handleSettingsRoleQuota,
+				"reset":            b.handleSettingsReset,
+			},
+		},
+		{
+			Data:              api.CreateCommandData{Name: "bot_admin_roles"},
+			Permissions:       guildPerms,
+			StrictPermissions: true,
+			Subcommands: []Subcommand{
+				{
+					Option: discord.SubcommandOption{
+						OptionName: "add",
+						Options: []discord.CommandOptionValue{
+							&discord.RoleOption{OptionName: "role", Required: true},
+						},
+					},
+					Handler: b.handleAddBotAdminRole,
+				},
+				{
+					Option: discord.SubcommandOption{
+						OptionName: "remove",
+						Options: []discord.CommandOptionValue{
+							&discord.RoleOption{OptionName: "role", Required: true},
+						},
+					},
+					Handler: b.handleRemoveBotAdminRole,
+				},
+				{
+					Option:  discord.SubcommandOption{OptionName: "list"},
+					Handler: b.handleListBotAdminRoles,
+				},
+			},
+		},
+	}
+}
+
+// localizeCommands fills in the descriptions and choice names of the commands from the catalogues.
+// Keys are "cmd." followed by the dotted path of the command or option, names use the ":name" suffix and choices ":<value>".
+func localizeCommands(cmds []api.CreateCommandData) []api.CreateCommandData {
+	for i := range cmds {
+		cmd := &cmds[i]
+		key := "cmd." + cmd.Name
+		cmd.NameLocalizations = localizations(key + ":name")
+		if cmd.Type == discord.ChatInputCommand {
+			cmd.Description, cmd.DescriptionLocalizations = localizeDescription(key)
+		}
+		localizeOptions(key, cmd.Options)
+	}
+	return cmds
+}
+
+func localizeOptions(prefix string, options []discord.CommandOption) {
+	for _, option := range options {
+		key := prefix + "." + option.Name()
+		description, descriptions := localizeDescription(key)
+		switch o := option.(type) {
+		case *discord.SubcommandGroupOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+			subcommands := make([]discord.CommandOption, len(o.Subcommands))
+			for i, sub := range o.Subcommands {
+				subcommands[i] = sub
+			}
+			localizeOptions(key, subcommands)
+		case *discord.SubcommandOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+			values := make([]discord.CommandOption, len(o.Options))
+			for i, value := range o.Options {
+				values[i] = value
+			}
+			localizeOptions(key, values)
+		case *discord.StringOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+			for i := range o.Choices {
+				localizeChoice(key, o.Choices[i].Value, &o.Choices[i].Name, &o.Choices[i].NameLocalizations)
+			}
+		case *discord.IntegerOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+			for i := range o.Choices {
+				localizeChoice(key, strconv.Itoa(o.Choices[i].Value), &o.Choices[i].Name, &o.Choices[i].NameLocalizations)
+			}
+		case *discord.BooleanOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		case *discord.UserOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		case *discord.ChannelOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		case *discord.RoleOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		case *discord.MentionableOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		case *discord.NumberOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		case *discord.AttachmentOption:
+			o.Description, o.DescriptionLocalizations = description, descriptions
+		}
+	}
+}
+
+// localizeDescription returns the description in defaultLanguage and its translations.
+func localizeDescription(key string) (string, discord.StringLocales) {
+	descriptions := localizations(key)
+	delete(descriptions, defaultLanguage)
+	return tr(defaultLanguage, key), descriptions
+}
+
+// localizeChoice names the choice from the catalogues, choices without messages such as embed types keep their names.
+func localizeChoice(prefix, value string, name *string, names *discord.StringLocales) {
+	key := prefix + ":" + value
+	if _, ok := catalogues[defaultLanguage][key]; !ok {
+		return
+	}
+	*name = tr(defaultLanguage, key)
+	*names = localizations(key)
+	delete(*names, defaultLanguage)
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"log"
+	"strings"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var embedTypes = []discord.EmbedType{
+	discord.NormalEmbed,
+	discord.ImageEmbed,
+	discord.VideoEmbed,
+	discord.GIFVEmbed,
+	discord.ArticleEmbed,
+	discord.LinkEmbed,
+}
+
+func embedTypeChoices() []discord.StringChoice {
+	choices := make([]discord.StringChoice, len(embedTypes))
+	for i, t := range embedTypes {
+		choices[i] = discord.StringChoice{Name: string(t), Value: string(t)}
+	}
+	return choices
+}
+
+// embedCostOf looks up the cost of a single embed.
+// Provider specific entries take precedence over type wide entries, unconfigured embeds cost 1.
+func embedCostOf(embed discord.Embed, costs []storage.EmbedCost) int {
+	provider := ""
+	if embed.Provider != nil {
+		provider = embed.Provider.Name
+	}
+
+	cost := 1
+	for _, c := range costs {
+		if c.EmbedType != string(embed.Type) {
+			continue
+		}
+		if c.Provider == "" {
+			cost = c.Cost
+		} else if strings.EqualFold(c.Provider, provider) {
+			return c.Cost
+		}
+	}
+	return cost
+}
+
+func (b *Bot) getEmbedCosts(guildID discord.GuildID) []storage.EmbedCost {
+	if !guildID.IsValid() {
+		return nil
+	}
+	costs, err := b.storage.GetEmbedCosts(uint64(guildID))
+	if err != nil {
+		log.Printf("Error getting embed costs: %v", err)
+	}
+	return costs
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// deferredTokenTTL is how long the token of a deferred interaction can be used to follow up.
+const deferredTokenTTL = 15 * time.Minute
+
+var errResponseDeferred = errors.New("the response has been deferred")
+
+// interactionReply is whether an interaction has been responded to, or deferred so it must be followed up.
+type interactionReply struct {
+	mu        sync.Mutex
+	responded bool
+	// deferType is the deferred response sent, zero if not deferred.
+	deferType api.InteractionResponseType
+	// direct receives the first response instead of Discord, for interactions received over HTTP
+	// which are responded to in the HTTP response.
+	direct chan<- api.InteractionResponse
+}
+
+// replyClient responds to the interactions tracked by interactionTokenCache. Once the response of an
+// interaction is deferred, responding to it edits the deferred response through the interaction webhook.
+type replyClient struct {
+	Discord
+}
+
+func (c replyClient) RespondInteraction(id discord.InteractionID, token string, resp api.InteractionResponse) error {
+	entry, ok := interactionTokenCache.Get(token)
+	if !ok || entry.reply == nil {
+		return c.Discord.RespondInteraction(id, token, resp)
+	}
+
+	r := entry.reply
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.deferType == 0 {
+		err := c.send(r, id, token, resp)
+		if err == nil {
+			r.responded = true
+		}
+		return err
+	}
+
+	data := resp.Data
+	if data == nil {
+		data = &api.InteractionResponseData{}
+	}
+	switch {
+	case resp.Type == api.UpdateMessage,
+		resp.Type == api.MessageInteractionWithSource && r.deferType == api.DeferredMessageInteractionWithSource:
+		_, err := c.EditInteractionResponse(entry.AppID, token, api.EditInteractionResponseData{
+			Content:         data.Content,
+			Embeds:          data.Embeds,
+			Components:      data.Components,
+			AllowedMentions: data.AllowedMentions,
+		})
+		return err
+	case resp.Type == api.MessageInteractionWithSource:
+		// The deferred update keeps the message of the component, the response is sent besides it
+		_, err := c.FollowUpInteraction(entry.AppID, token, *data)
+		return err
+	default:
+		return errResponseDeferred
+	}
+}
+
+// send sends the response to Discord, or to direct if it hasn't received one yet. r must be locked.
+func (c replyClient) send(r *interactionReply, id discord.InteractionID, token string, resp api.InteractionResponse) error {
+	if r.direct == nil {
+		return c.Discord.RespondInteraction(id, token, resp)
+	}
+	r.direct <- resp
+	r.direct = nil
+	return nil
+}
+
+// deferMiddleware defers the response if the handler hasn't responded in time, so slow handlers don't fail
+// the interaction. Commands are deferred with an ephemeral response, components and modals with an update
+// of their message. Autocomplete can't be deferred.
+func (b *Bot) deferMiddleware(e *gateway.InteractionCreateEvent, state *InteractionHandlerState, next ...Middleware[InteractionHandlerState]) error {
+	if len(next) == 0 {
+		return nil
+	}
+	rc, ok := b.client.(replyClient)
+	entry, tracked := interactionTokenCache.Get(e.Token)
+	if _, autocomplete := e.Data.(*discord.AutocompleteInteraction); !ok || !tracked || entry.reply == nil || autocomplete {
+		return next[0](e, state, next[1:]...)
+	}
+
+	timer := time.AfterFunc(b.deferAfter, func() {
+		if err := rc.deferResponse(e, entry); err != nil {
+			log.Printf("Error deferring response: %v", err)
+		}
+	})
+	defer timer.Stop()
+	return next[0](e, state, next[1:]...)
+}
+
+// deferResponse sends the deferred response unless the interaction has been responded to.
+func (c replyClient) deferResponse(e *gateway.InteractionCreateEvent, entry InteractionTokenCache) error {
+	r := entry.reply
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.responded || r.deferType != 0 {
+		return nil
+	}
+
+	resp := api.InteractionResponse{Type: api.DeferredMessageUpdate}
+	if e.Data.InteractionType() == discord.CommandInteractionType {
+		resp = api.InteractionResponse{
+			Type: api.DeferredMessageInteractionWithSource,
+			Data: &api.InteractionResponseData{Flags: discord.EphemeralMessage},
+		}
+	}
+	if err := c.send(r, e.ID, e.Token, resp); err != nil {
+		return err
+	}
+	r.deferType = resp.Type
+	// The token stays valid for follow ups, until the handler is done
+	interactionTokenCache.Set(e.Token, entry, deferredTokenTTL)
+	log.Printf("Deferred response of %s after %s", entry.Id, time.Since(entry.CreatedAt))
+	return nil
+}
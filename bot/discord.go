@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+// Discord is the subset of the Discord API used by the bot. It is implemented by stateClient,
+// and by fakediscord.Discord in replays and tests.
+type Discord interface {
+	Me() (*discord.User, error)
+	Message(channelID discord.ChannelID, messageID discord.MessageID) (*discord.Message, error)
+	SendMessage(channelID discord.ChannelID, content string, embeds ...discord.Embed) (*discord.Message, error)
+	SendMessageComplex(channelID discord.ChannelID, data api.SendMessageData) (*discord.Message, error)
+	EditMessageComplex(channelID discord.ChannelID, messageID discord.MessageID, data api.EditMessageData) (*discord.Message, error)
+	DeleteMessage(channelID discord.ChannelID, messageID discord.MessageID, reason api.AuditLogReason) error
+	React(channelID discord.ChannelID, messageID discord.MessageID, emoji discord.APIEmoji) error
+	CreatePrivateChannel(recipientID discord.UserID) (*discord.Channel, error)
+	RespondInteraction(id discord.InteractionID, token string, resp api.InteractionResponse) error
+	EditInteractionResponse(appID discord.AppID, token string, data api.EditInteractionResponseData) (*discord.Message, error)
+	FollowUpInteraction(appID discord.AppID, token string, data api.InteractionResponseData) (*discord.Message, error)
+	Permissions(channelID discord.ChannelID, userID discord.UserID) (discord.Permissions, error)
+	Channels(guildID discord.GuildID) ([]discord.Channel, error)
+	Roles(guildID discord.GuildID) ([]discord.Role, error)
+	Commands(appID discord.AppID) ([]discord.Command, error)
+	CreateCommand(appID discord.AppID, data api.CreateCommandData) (*discord.Command, error)
+	EditCommand(appID discord.AppID, commandID discord.CommandID, data api.CreateCommandData) (*discord.Command, error)
+	DeleteCommand(appID discord.AppID, commandID discord.CommandID) error
+	BulkOverwriteCommands(appID discord.AppID, commands []api.CreateCommandData) ([]discord.Command, error)
+	GuildCommands(appID discord.AppID, guildID discord.GuildID) ([]discord.Command, error)
+	CreateGuildCommand(appID discord.AppID, guildID discord.GuildID, data api.CreateCommandData) (*discord.Command, error)
+	EditGuildCommand(appID discord.AppID, guildID discord.GuildID, commandID discord.CommandID, data api.CreateCommandData) (*discord.Command, error)
+	DeleteGuildCommand(appID discord.AppID, guildID discord.GuildID, commandID discord.CommandID) error
+	BulkOverwriteGuildCommands(appID discord.AppID, guildID discord.GuildID, commands []api.CreateCommandData) ([]discord.Command, error)
+}
+
+// stateClient is the Discord client of a gateway connection.
+type stateClient struct {
+	*state.State
+}
+
+var _ Discord = stateClient{}
+
+// Commands fetches the global commands including their localizations, which arikawa leaves out.
+func (c stateClient) Commands(appID discord.AppID) ([]discord.Command, error) {
+	var cmds []discord.Command
+	return cmds, c.RequestJSON(&cmds, "GET", api.EndpointApplications+appID.String()+"/commands?with_localizations=true")
+}
+
+// GuildCommands fetches the commands of the guild including their localizations.
+func (c stateClient) GuildCommands(appID discord.AppID, guildID discord.GuildID) ([]discord.Command, error) {
+	var cmds []discord.Command
+	return cmds, c.RequestJSON(&cmds, "GET", api.EndpointApplications+appID.String()+"/guilds/"+guildID.String()+"/commands?with_localizations=true")
+}
@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const maxDuplicateWindow = 7 * 24 * time.Hour
+
+// trackingParams are query parameters that do not change what a link points to.
+var trackingParams = map[string]struct{}{
+	"fbclid":  {},
+	"gclid":   {},
+	"igshid":  {},
+	"igsh":    {},
+	"mc_cid":  {},
+	"mc_eid":  {},
+	"ref_src": {},
+	"si":      {},
+	"feature": {},
+}
+
+// hostTrackingParams are tracking parameters of a site only, elsewhere they may change what a link
+// points to, e.g. t is the timestamp of a YouTube video.
+var hostTrackingParams = map[string][]string{
+	"x.com":       {"s", "t"},
+	"twitter.com": {"s", "t"},
+}
+
+// normalizeURL makes reposts of a link compare equal, e.g. by dropping tracking parameters and fragments.
+func normalizeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+
+	u.Scheme = "https"
+	u.Host = normalizeHost(u.Host)
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if _, ok := trackingParams[lower]; ok || strings.HasPrefix(lower, "utm_") || slices.Contains(hostTrackingParams[u.Host], lower) {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sb := strings.Builder{}
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(key))
+			sb.WriteByte('=')
+			sb.WriteString(url.QueryEscape(value))
+		}
+	}
+	u.RawQuery = sb.String()
+
+	return u.String(), true
+}
+
+// messageURLs returns the normalized links of the message and its forwarded snapshots that Discord would embed.
+func messageURLs(m *discord.Message) []string {
+	contents := []string{m.Content}
+	for _, snapshot := range m.MessageSnapshots {
+		contents = append(contents, snapshot.Message.Content)
+	}
+
+	var urls []string
+	seen := make(map[string]struct{})
+	for _, content := range contents {
+		for _, match := range findLinks(content) {
+			normalized, ok := normalizeURL(match)
+			if !ok {
+				continue
+			}
+			if _, ok := seen[normalized]; ok {
+				continue
+			}
+			seen[normalized] = struct{}{}
+			urls = append(urls, normalized)
+		}
+	}
+	return urls
+}
+
+// findDuplicate checks whether every link of the message has been posted in the channel within the duplicate window,
+// and returns the message one of the links has first been posted in. Links not seen before are recorded.
+func (b *Bot) findDuplicate(m *gateway.MessageCreateEvent) (discord.MessageID, bool) {
+	window, err := b.storage.GetDuplicateWindow(uint64(m.ChannelID))
+	if err != nil {
+		log.Printf("Error getting duplicate window: %v", err)
+		return 0, false
+	}
+	if window <= 0 {
+		return 0, false
+	}
+
+	urls := messageURLs(&m.Message)
+	if len(urls) == 0 {
+		return 0, false
+	}
+
+	postedAt := m.Timestamp.Time()
+	var first uint64
+	var fresh []string
+	for _, u := range urls {
+		messageID, err := b.storage.FindRecentURL(uint64(m.ChannelID), u, postedAt.Add(-window))
+		if errors.Is(err, sql.ErrNoRows) {
+			fresh = append(fresh, u)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error finding recent url: %v", err)
+			return 0, false
+		}
+		if messageID != uint64(m.ID) && first == 0 {
+			first = messageID
+		}
+	}
+
+	for _, u := range fresh {
+		err = b.storage.RecordRecentURL(uint64(m.ChannelID), u, uint64(m.ID), postedAt)
+		if err != nil {
+			log.Printf("Error recording recent url: %v", err)
+		}
+	}
+
+	if len(fresh) > 0 || first == 0 {
+		return 0, false
+	}
+	return discord.MessageID(first), true
+}
+
+// suppressDuplicate suppresses the repost without charging any quota, and points to the first occurrence.
+func (b *Bot) suppressDuplicate(m *discord.Message, first discord.MessageID) error {
+	err := b.suppressEmbeds(m)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Message %d in #%d is a repost of %d", m.ID, m.ChannelID, first)
+	jump := fmt.Sprintf("https://discord.com/channels/%d/%d/%d", m.GuildID, m.ChannelID, first)
+	return b.replyTracked(m, tr(b.guildLanguage(m.GuildID, ""), "duplicate.reply", jump), discord.SuppressEmbeds)
+}
+
+func (b *Bot) pruneRecentURLsLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := b.storage.PruneRecentURLs(b.clock.Now().Add(-maxDuplicateWindow))
+			if err != nil {
+				log.Printf("Error pruning recent urls: %v", err)
+			}
+		}
+	}
+}
+
+func (b *Bot) handleSetDuplicateWindow(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	minutes, err := data.Options.Find("minutes").IntValue()
+	if err != nil {
+		return err
+	}
+
+	window := time.Duration(minutes) * time.Minute
+	if window < 0 {
+		window = 0
+	}
+	if window > maxDuplicateWindow {
+		return b.RespondError(i, "error.duplicate_window_too_long", int(maxDuplicateWindow/time.Minute))
+	}
+
+	err = b.storage.SetDuplicateWindow(uint64(i.ChannelID), window)
+	if err != nil {
+		return err
+	}
+
+	var msg string
+	if window == 0 {
+		msg = tr(interactionLanguage(i), "duplicate_window.disabled")
+	} else {
+		msg = tr(interactionLanguage(i), "duplicate_window.set", minutes)
+	}
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
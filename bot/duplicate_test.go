@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	for input, expected := range map[string]string{
+		"https://example.com/article":                                   "https://example.com/article",
+		"http://www.Example.com/article/":                               "https://example.com/article",
+		"https://example.com/article?utm_source=x&utm_medium=y":         "https://example.com/article",
+		"https://example.com/article?id=2&fbclid=abc&page=1#comments":   "https://example.com/article?id=2&page=1",
+		"https://example.com/article?page=1&id=2":                       "https://example.com/article?id=2&page=1",
+		"https://x.com/user/status/1?s=20&t=abc":                        "https://x.com/user/status/1",
+		"https://www.youtube.com/watch?v=abc&si=tracking&feature=share": "https://youtube.com/watch?v=abc",
+		"https://youtube.com/watch?v=1&t=30":                            "https://youtube.com/watch?t=30&v=1",
+		"https://example.com/search?s=query":                            "https://example.com/search?s=query",
+	} {
+		normalized, ok := normalizeURL(input)
+		if !ok {
+			t.Errorf("normalizeURL(%q) failed", input)
+			continue
+		}
+		if normalized != expected {
+			t.Errorf("normalizeURL(%q): expected %q, got %q", input, expected, normalized)
+		}
+	}
+
+	if _, ok := normalizeURL("https://"); ok {
+		t.Errorf("Expected url without host to be rejected")
+	}
+}
+
+func TestMessageURLs(t *testing.T) {
+	m := forwardEvent("https://example.com/a?utm_source=x https://example.com/a <https://example.com/b>", nil,
+		discord.MessageSnapshotMessage{Content: "https://example.com/c"},
+	)
+
+	urls := messageURLs(&m.Message)
+	expected := []string{"https://example.com/a", "https://example.com/c"}
+	if len(urls) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, urls)
+	}
+	for i := range expected {
+		if urls[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, urls)
+		}
+	}
+
+	// The same links closed by punctuation or markdown are duplicates
+	m = forwardEvent("(https://example.com/a), **https://example.com/a**. [c](https://example.com/c)", nil)
+	urls = messageURLs(&m.Message)
+	if len(urls) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, urls)
+	}
+	for i := range expected {
+		if urls[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, urls)
+		}
+	}
+}
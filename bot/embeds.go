@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// messageEmbeds is what a message is charged for, after walking the message and all of its forwarded snapshots.
+type messageEmbeds struct {
+	// Embeds are the chargeable embeds.
+	Embeds []discord.Embed
+	// Exempted is the number of embeds that are exempted from throttling.
+	Exempted int
+	Cost     int
+}
+
+// isExemptEmbed reports embeds that are never throttled, e.g. Tenor gifs.
+func isExemptEmbed(embed discord.Embed) bool {
+	return embed.Provider != nil && embed.Provider.Name == "Tenor" && (embed.Video != nil || embed.Image != nil)
+}
+
+// allEmbeds returns the embeds of the message followed by the embeds of every forwarded snapshot.
+func allEmbeds(m *discord.Message) []discord.Embed {
+	if len(m.MessageSnapshots) == 0 {
+		return m.Embeds
+	}
+
+	embeds := make([]discord.Embed, 0, len(m.Embeds))
+	embeds = append(embeds, m.Embeds...)
+	for _, snapshot := range m.MessageSnapshots {
+		embeds = append(embeds, snapshot.Message.Embeds...)
+	}
+	return embeds
+}
+
+func extractEmbeds(m *discord.Message, costs []storage.EmbedCost) messageEmbeds {
+	var extracted messageEmbeds
+	for _, embed := range allEmbeds(m) {
+		if isExemptEmbed(embed) {
+			extracted.Exempted++
+			continue
+		}
+		extracted.Embeds = append(extracted.Embeds, embed)
+		extracted.Cost += embedCostOf(embed, costs)
+	}
+	return extracted
+}
+
+// countPotentialLinks counts the links in the message and its forwarded snapshots that may get embedded later.
+func countPotentialLinks(m *discord.Message) int {
+	count := strings.Count(m.Content, "http")
+	for _, snapshot := range m.MessageSnapshots {
+		count += strings.Count(snapshot.Message.Content, "http")
+	}
+	return count
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+func linkEmbed(provider string) discord.Embed {
+	return discord.Embed{
+		Type:     discord.LinkEmbed,
+		Provider: &discord.EmbedProvider{Name: provider},
+	}
+}
+
+func tenorEmbed() discord.Embed {
+	return discord.Embed{
+		Type:     discord.GIFVEmbed,
+		Provider: &discord.EmbedProvider{Name: "Tenor"},
+		Video:    &discord.EmbedVideo{URL: "https://media.tenor.com/x.mp4"},
+	}
+}
+
+func forwardEvent(content string, embeds []discord.Embed, snapshots ...discord.MessageSnapshotMessage) *gateway.MessageCreateEvent {
+	m := &gateway.MessageCreateEvent{
+		Message: discord.Message{
+			ID:        1,
+			ChannelID: 2,
+			GuildID:   3,
+			Content:   content,
+			Embeds:    embeds,
+		},
+	}
+	for _, snapshot := range snapshots {
+		m.MessageSnapshots = append(m.MessageSnapshots, discord.MessageSnapshot{Message: snapshot})
+	}
+	return m
+}
+
+func TestExtractEmbeds(t *testing.T) {
+	costs := []storage.EmbedCost{
+		{EmbedType: string(discord.ImageEmbed), Cost: 0},
+		{EmbedType: string(discord.VideoEmbed), Cost: 2},
+		{EmbedType: string(discord.VideoEmbed), Provider: "YouTube", Cost: 3},
+	}
+
+	tests := []struct {
+		name     string
+		event    *gateway.MessageCreateEvent
+		embeds   int
+		exempted int
+		cost     int
+	}{
+		{
+			name:  "no embeds",
+			event: forwardEvent("hello", nil),
+		},
+		{
+			name:   "plain message",
+			event:  forwardEvent("https://example.com", []discord.Embed{linkEmbed("Example"), linkEmbed("Example")}),
+			embeds: 2,
+			cost:   2,
+		},
+		{
+			name:     "tenor gif",
+			event:    forwardEvent("https://tenor.com/view/x", []discord.Embed{tenorEmbed()}),
+			exempted: 1,
+		},
+		{
+			name: "typed costs",
+			event: forwardEvent("", []discord.Embed{
+				{Type: discord.ImageEmbed},
+				{Type: discord.VideoEmbed, Provider: &discord.EmbedProvider{Name: "Vimeo"}},
+				{Type: discord.VideoEmbed, Provider: &discord.EmbedProvider{Name: "youtube"}},
+				{Type: discord.ArticleEmbed},
+			}),
+			embeds: 4,
+			cost:   0 + 2 + 3 + 1,
+		},
+		{
+			name: "single snapshot",
+			event: forwardEvent("", nil, discord.MessageSnapshotMessage{
+				Content: "https://example.com",
+				Embeds:  []discord.Embed{linkEmbed("Example")},
+			}),
+			embeds: 1,
+			cost:   1,
+		},
+		{
+			name: "multiple snapshots",
+			event: forwardEvent("", nil,
+				discord.MessageSnapshotMessage{Embeds: []discord.Embed{linkEmbed("Example")}},
+				discord.MessageSnapshotMessage{Content: "no links"},
+				discord.MessageSnapshotMessage{Embeds: []discord.Embed{linkEmbed("Example"), {Type: discord.VideoEmbed}}},
+			),
+			embeds: 3,
+			cost:   1 + 1 + 2,
+		},
+		{
+			name: "forwarded tenor gif",
+			event: forwardEvent("", nil, discord.MessageSnapshotMessage{
+				Embeds: []discord.Embed{tenorEmbed()},
+			}),
+			exempted: 1,
+		},
+		{
+			name: "forwarded tenor gif with comment link",
+			event: forwardEvent("https://example.com", []discord.Embed{linkEmbed("Example")}, discord.MessageSnapshotMessage{
+				Embeds: []discord.Embed{tenorEmbed(), linkEmbed("Example")},
+			}),
+			embeds:   2,
+			exempted: 1,
+			cost:     2,
+		},
+		{
+			name:   "embed without provider",
+			event:  forwardEvent("", []discord.Embed{{Type: discord.NormalEmbed}}),
+			embeds: 1,
+			cost:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extracted := extractEmbeds(&tt.event.Message, costs)
+			if len(extracted.Embeds) != tt.embeds {
+				t.Errorf("Expected %d chargeable embeds, got %d", tt.embeds, len(extracted.Embeds))
+			}
+			if extracted.Exempted != tt.exempted {
+				t.Errorf("Expected %d exempted embeds, got %d", tt.exempted, extracted.Exempted)
+			}
+			if extracted.Cost != tt.cost {
+				t.Errorf("Expected cost %d, got %d", tt.cost, extracted.Cost)
+			}
+		})
+	}
+}
+
+func TestCountPotentialLinks(t *testing.T) {
+	m := forwardEvent("see https://a.example", nil,
+		discord.MessageSnapshotMessage{Content: "http://b.example"},
+		discord.MessageSnapshotMessage{Content: "https://c.example https://d.example"},
+	)
+	if count := countPotentialLinks(&m.Message); count != 4 {
+		t.Errorf("Expected 4 potential links, got %d", count)
+	}
+}
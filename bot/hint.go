@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// Hint modes chosen by users with /embed_hints, users who have not chosen follow the guild's hint delivery.
+const (
+	hintModeOn      = "on"
+	hintModeOff     = "off"
+	hintModeChannel = "channel"
+)
+
+// Hint deliveries chosen by guilds with /set_hint_delivery.
+const (
+	hintDeliveryDM      = "dm"
+	hintDeliveryChannel = "channel"
+)
+
+// hintReplyTTL is how long hints replied in the channel are kept before being deleted.
+const hintReplyTTL = 30 * time.Second
+
+func renderHint(template string, channelID discord.ChannelID, userID discord.UserID, cooldown time.Duration) string {
+	return strings.NewReplacer(
+		"{channel}", fmt.Sprintf("<#%d>", channelID),
+		"{user}", fmt.Sprintf("<@%d>", userID),
+		"{hours}", strconv.Itoa(int(cooldown/time.Hour)),
+	).Replace(template)
+}
+
+// hintContent renders the guild's template for the reason, or the default hint in the user's or the guild's language.
+// Templates and default hints may use {channel}, {user} and {hours}.
+func (b *Bot) hintContent(m *discord.Message, reason suppressReason, cooldown time.Duration, userLocale string) string {
+	if reason != reasonBurst {
+		reason = reasonQuota
+	}
+
+	template, err := b.storage.GetHintTemplate(uint64(m.GuildID), reason.String())
+	if err != nil {
+		log.Printf("Error getting hint template of guild %d: %v", m.GuildID, err)
+	}
+	if template == "" {
+		template = tr(b.guildLanguage(m.GuildID, userLocale), "hint."+reason.String())
+	}
+	return renderHint(template, m.ChannelID, m.Author.ID, cooldown)
+}
+
+// sendHint tells the author why the message has been suppressed, the way the author or the guild prefers.
+// It reports false if the author has opted out of hints.
+func (b *Bot) sendHint(m *discord.Message, reason suppressReason, cooldown time.Duration) bool {
+	user, err := b.storage.GetUser(uint64(m.Author.ID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting user %d: %v", m.Author.ID, err)
+	}
+
+	mode := user.HintMode
+	if mode == "" {
+		delivery, err := b.storage.GetGuildHintDelivery(uint64(m.GuildID))
+		if err != nil {
+			log.Printf("Error getting hint delivery of guild %d: %v", m.GuildID, err)
+		}
+		mode = hintModeOn
+		if delivery == hintDeliveryChannel {
+			mode = hintModeChannel
+		}
+	}
+	if mode == hintModeOff {
+		return false
+	}
+
+	content := b.hintContent(m, reason, cooldown, user.Locale)
+	switch mode {
+	case hintModeChannel:
+		reply, err := b.client.SendMessageComplex(m.ChannelID, api.SendMessageData{
+			Content:         content,
+			Reference:       &discord.MessageReference{MessageID: m.ID},
+			AllowedMentions: &api.AllowedMentions{Parse: []api.AllowedMentionType{}, RepliedUser: option.True},
+			Flags:           discord.SuppressEmbeds,
+		})
+		if err != nil {
+			log.Printf("Error replying hint: %v", err)
+			break
+		}
+		time.AfterFunc(hintReplyTTL, func() {
+			err := b.client.DeleteMessage(reply.ChannelID, reply.ID, "")
+			if err != nil {
+				log.Printf("Error deleting hint %d: %v", reply.ID, err)
+			}
+		})
+	default:
+		ch, err := b.client.CreatePrivateChannel(m.Author.ID)
+		if err != nil {
+			log.Printf("Error creating private channel: %v", err)
+			break
+		}
+
+		_, err = b.client.SendMessage(ch.ID, content)
+		if err != nil {
+			log.Printf("Error sending message: %v", err)
+		}
+	}
+	return true
+}
+
+// rememberLocales keeps the locales of interactions, to hint users in their language outside of interactions.
+// Autocompletes are left out, they come with every keystroke, and locales are only written when they change.
+func (b *Bot) rememberLocales(e *gateway.InteractionCreateEvent) {
+	if _, ok := e.Data.(*discord.AutocompleteInteraction); ok {
+		return
+	}
+	if e.Locale != "" && b.localeChanged(uint64(e.SenderID()), string(e.Locale)) {
+		err := b.storage.SetUserLocale(uint64(e.SenderID()), string(e.Locale))
+		if err != nil {
+			log.Printf("Error setting locale of user %d: %v", e.SenderID(), err)
+			b.knownLocales.Delete(uint64(e.SenderID()))
+		}
+	}
+	if e.GuildLocale != "" && e.GuildID.IsValid() && b.localeChanged(uint64(e.GuildID), e.GuildLocale) {
+		err := b.storage.SetGuildLocale(uint64(e.GuildID), e.GuildLocale)
+		if err != nil {
+			log.Printf("Error setting locale of guild %d: %v", e.GuildID, err)
+			b.knownLocales.Delete(uint64(e.GuildID))
+		}
+	}
+}
+
+// localeChanged reports whether the locale of the user or guild differs from the one last stored, and takes it as stored.
+// Users and guilds share the cache, as snowflakes are unique across both.
+func (b *Bot) localeChanged(id uint64, locale string) bool {
+	if known, ok := b.knownLocales.Get(id); ok && known == locale {
+		return false
+	}
+	b.knownLocales.Set(id, locale)
+	return true
+}
+
+func (b *Bot) handleEmbedHints(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	mode := data.Options.Find("mode").String()
+
+	switch mode {
+	case hintModeOn, hintModeOff, hintModeChannel:
+	default:
+		return b.RespondError(i, "error.unknown_choice")
+	}
+
+	err := b.storage.SetUserHintMode(uint64(i.SenderID()), mode)
+	if err != nil {
+		return err
+	}
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(tr(interactionLanguage(i), "embed_hints."+mode)),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleSetHintDelivery(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	delivery := data.Options.Find("delivery").String()
+
+	var msg string
+	switch delivery {
+	case hintDeliveryDM:
+		msg = tr(interactionLanguage(i), "hint_delivery.dm")
+	case hintDeliveryChannel:
+		msg = tr(interactionLanguage(i), "hint_delivery.channel", int(hintReplyTTL/time.Second))
+	default:
+		return b.RespondError(i, "error.unknown_choice")
+	}
+
+	err := b.storage.SetGuildHintDelivery(uint64(i.GuildID), delivery)
+	if err != nil {
+		return err
+	}
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleSetHintTemplate(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	reason := data.Options.Find("reason").String()
+	if reason != reasonQuota.String() && reason != reasonBurst.String() {
+		return b.RespondError(i, "error.unknown_choice")
+	}
+	// Newlines can't be typed in slash command options
+	template := strings.ReplaceAll(strings.TrimSpace(data.Options.Find("template").String()), `\n`, "\n")
+
+	err := b.storage.SetHintTemplate(uint64(i.GuildID), reason, template)
+	if err != nil {
+		return err
+	}
+
+	var msg string
+	if template == "" {
+		msg = tr(interactionLanguage(i), "hint_template.reset")
+	} else {
+		preview := renderHint(template, i.ChannelID, i.SenderID(), hintCooldown(0))
+		msg = tr(interactionLanguage(i), "hint_template.set", preview)
+	}
+	respd := api.InteractionResponseData{
+		Content:         option.NewNullableString(msg),
+		Flags:           discord.EphemeralMessage,
+		AllowedMentions: &api.AllowedMentions{Parse: []api.AllowedMentionType{}},
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+)
+
+func init() {
+	// The interactions of the gateway are decoded with their permissions, see interactionCreateEvent
+	gateway.OpUnmarshalers.Add(func() ws.Event { return new(interactionCreateEvent) })
+}
+
+// interactionCreateEvent is an interaction with the permissions Discord resolves in its payload for the
+// channel it is used in, which arikawa doesn't decode. It replaces the interaction event of the gateway.
+type interactionCreateEvent struct {
+	gateway.InteractionCreateEvent
+	// MemberPermissions are the permissions of the member, zero outside of guilds.
+	MemberPermissions discord.Permissions
+	// AppPermissions are the permissions of the bot.
+	AppPermissions discord.Permissions
+}
+
+// payloadPermissions are the permissions in the payload of an interaction.
+type payloadPermissions struct {
+	Member *struct {
+		Permissions discord.Permissions `json:"permissions,string"`
+	} `json:"member,omitempty"`
+	AppPermissions discord.Permissions `json:"app_permissions,string"`
+}
+
+func (e *interactionCreateEvent) UnmarshalJSON(b []byte) error {
+	if err := e.InteractionEvent.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	var perms payloadPermissions
+	if err := json.Unmarshal(b, &perms); err != nil {
+		return err
+	}
+	if perms.Member != nil {
+		e.MemberPermissions = perms.Member.Permissions
+	}
+	e.AppPermissions = perms.AppPermissions
+	return nil
+}
+
+// MarshalJSON encodes the permissions back into the payload, so recordings keep them.
+func (e *interactionCreateEvent) MarshalJSON() ([]byte, error) {
+	raw, err := e.InteractionEvent.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	if member, ok := fields["member"]; ok {
+		var memberFields map[string]json.RawMessage
+		if err := json.Unmarshal(member, &memberFields); err != nil {
+			return nil, err
+		}
+		memberFields["permissions"], _ = json.Marshal(strconv.FormatUint(uint64(e.MemberPermissions), 10))
+		if fields["member"], err = json.Marshal(memberFields); err != nil {
+			return nil, err
+		}
+	}
+	fields["app_permissions"], _ = json.Marshal(strconv.FormatUint(uint64(e.AppPermissions), 10))
+	return json.Marshal(fields)
+}
+
+// maxInteractionSize is the largest request accepted by the interactions endpoint.
+const maxInteractionSize = 1 << 20
+
+// interactionServer is the HTTP handler of the interactions endpoint.
+type interactionServer struct {
+	bot       *Bot
+	publicKey ed25519.PublicKey
+}
+
+// NewInteractionServer creates the HTTP handler of the interactions endpoint, verifying the requests are
+// signed with the hex-encoded public key of the application.
+func (b *Bot) NewInteractionServer(publicKey string) (http.Handler, error) {
+	// Without a key the server would accept unsigned requests
+	if publicKey == "" {
+		return nil, errors.New("the public key of the application is required")
+	}
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key %q", publicKey)
+	}
+	return &interactionServer{bot: b, publicKey: key}, nil
+}
+
+func (s *interactionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The body is read before it is verified, so its size is limited
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInteractionSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+	sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	if err != nil || timestamp == "" || !ed25519.Verify(s.publicKey, append([]byte(timestamp), body...), sig) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var ev interactionCreateEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		http.Error(w, "cannot decode interaction", http.StatusBadRequest)
+		return
+	}
+	resp := &api.InteractionResponse{Type: api.PongInteraction}
+	if _, ok := ev.Data.(*discord.PingInteraction); !ok {
+		resp = s.bot.handleHTTPInteraction(&ev)
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleHTTPInteraction handles the interaction like the ones from the gateway, its first response is
+// returned to be written in the HTTP response. The handler keeps running after that to follow up.
+func (b *Bot) handleHTTPInteraction(ev *interactionCreateEvent) *api.InteractionResponse {
+	direct := make(chan api.InteractionResponse, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.handleInteraction(ev, &interactionReply{direct: direct})
+	}()
+
+	select {
+	case resp := <-direct:
+		return &resp
+	case <-done:
+		select {
+		case resp := <-direct:
+			return &resp
+		default:
+			log.Printf("Interaction %d over HTTP has not been responded to", ev.ID)
+			return nil
+		}
+	}
+}
+
+// ServeInteractions runs the bot as the interactions endpoint only, until ctx is done. No shard is opened,
+// Discord is called through the API, so the endpoint can be scaled apart from the gateway connections.
+func ServeInteractions(ctx context.Context, cfg *config.Config, store storage.Storage, clk clock.Clock) error {
+	if cfg.InteractionsAddr == "" {
+		return errors.New("the address of the interactions endpoint is required")
+	}
+	b, err := newBot(cfg, store, clk, stateClient{state.New("Bot " + cfg.Token)})
+	if err != nil {
+		return err
+	}
+	return b.serveInteractions(ctx)
+}
+
+// serveInteractions serves the interactions endpoint until ctx is done.
+func (b *Bot) serveInteractions(ctx context.Context) error {
+	handler, err := b.NewInteractionServer(b.config.InteractionsPublicKey)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              b.config.InteractionsAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Serving interactions on %s", b.config.InteractionsAddr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
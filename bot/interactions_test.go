@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+func TestInteractionServer(t *testing.T) {
+	s := newScenario(t)
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	server, err := s.bot.NewInteractionServer(hex.EncodeToString(public))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if _, err := s.bot.NewInteractionServer(""); err == nil {
+		t.Fatalf("Expected a public key to be required")
+	}
+
+	post := func(ev discord.InteractionEvent, perms discord.Permissions, key ed25519.PrivateKey) *httptest.ResponseRecorder {
+		t.Helper()
+		body, err := json.Marshal(&interactionCreateEvent{
+			InteractionCreateEvent: gateway.InteractionCreateEvent{InteractionEvent: ev},
+			MemberPermissions:      perms,
+			AppPermissions:         perms,
+		})
+		if err != nil {
+			t.Fatalf("Failed to encode interaction: %v", err)
+		}
+		const timestamp = "1735732800"
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, append([]byte(timestamp), body...))))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) api.InteractionResponse {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var resp api.InteractionResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response %q: %v", w.Body, err)
+		}
+		return resp
+	}
+
+	if resp := decode(post(discord.InteractionEvent{ID: 1, Data: &discord.PingInteraction{}}, 0, private)); resp.Type != api.PongInteraction {
+		t.Fatalf("Expected a pong, got %+v", resp)
+	}
+
+	ev := discord.InteractionEvent{
+		ID:        2,
+		AppID:     3,
+		Token:     t.Name(),
+		ChannelID: testChannelID,
+		GuildID:   testGuildID,
+		Member:    &discord.Member{User: discord.User{ID: testUserID}},
+		Data:      &discord.CommandInteraction{Name: "my_quota"},
+	}
+	resp := decode(post(ev, 0, private))
+	if resp.Type != api.MessageInteractionWithSource || resp.Data == nil || !strings.Contains(resp.Data.Content.Val, "3/3") {
+		t.Fatalf("Expected the quota in the HTTP response, got %+v", resp)
+	}
+	// The response isn't sent to Discord as well
+	s.expectCalls("RespondInteraction", 0)
+
+	// The permissions are the ones in the payload
+	ev.ID, ev.Data = 4, &discord.CommandInteraction{Name: "toggle_channel"}
+	if resp := decode(post(ev, discord.PermissionViewChannel, private)); !strings.Contains(resp.Data.Content.Val, "❌") {
+		t.Fatalf("Expected the command to be denied, got %+v", resp.Data)
+	}
+	if resp := decode(post(ev, discord.PermissionAll, private)); strings.Contains(resp.Data.Content.Val, "❌") {
+		t.Fatalf("Expected the command to be allowed, got %+v", resp.Data)
+	}
+
+	large := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(make([]byte, maxInteractionSize+1)))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, large)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected oversized requests to be rejected, got %d", w.Code)
+	}
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if w := post(ev, 0, other); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected requests signed with another key to be rejected, got %d", w.Code)
+	}
+	s.expectCalls("RespondInteraction", 0)
+}
+
+func TestInteractionPayloadPermissions(t *testing.T) {
+	arikawaEvent := &gateway.InteractionCreateEvent{}
+	if ev := gateway.OpUnmarshalers.Lookup(arikawaEvent.Op(), arikawaEvent.EventType())(); reflect.TypeOf(ev) != reflect.TypeOf(&interactionCreateEvent{}) {
+		t.Fatalf("Expected the gateway to decode interactions with their permissions, got %T", ev)
+	}
+
+	payload := `{"id":"1","type":2,"data":{"name":"my_quota"},"member":{"user":{"id":"30"},"permissions":"16"},"app_permissions":"8192"}`
+	var ev interactionCreateEvent
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		t.Fatalf("Failed to decode interaction: %v", err)
+	}
+	if ev.MemberPermissions != discord.PermissionManageChannels || ev.AppPermissions != discord.PermissionManageMessages || ev.Member.User.ID != 30 {
+		t.Fatalf("Expected the permissions of the payload, got %+v", ev)
+	}
+
+	raw, err := json.Marshal(&ev)
+	if err != nil {
+		t.Fatalf("Failed to encode interaction: %v", err)
+	}
+	var decoded interactionCreateEvent
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded.MemberPermissions != ev.MemberPermissions || decoded.AppPermissions != ev.AppPermissions {
+		t.Fatalf("Expected the permissions to be kept in %s, got %+v (%v)", raw, decoded, err)
+	}
+}
+
+func TestServeInteractions(t *testing.T) {
+	s := newScenario(t)
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	cfg := &config.Config{InteractionsPublicKey: hex.EncodeToString(public)}
+	if err := ServeInteractions(context.Background(), cfg, s.bot.storage, s.clock); err == nil {
+		t.Fatalf("Expected the address of the endpoint to be required")
+	}
+
+	// The endpoint is served without opening any shard, until the context is done
+	cfg.InteractionsAddr = "127.0.0.1:0"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ServeInteractions(ctx, cfg, s.bot.storage, s.clock); err != nil {
+		t.Fatalf("Failed to serve interactions: %v", err)
+	}
+}
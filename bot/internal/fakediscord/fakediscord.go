@@ -0,0 +1,478 @@
+// Package fakediscord provides an in-memory Discord client recording the calls made to it, for replays and tests.
+package fakediscord
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// Call is a recorded request to Discord. Only the fields relevant to the method are set.
+type Call struct {
+	Method    string
+	ChannelID discord.ChannelID
+	MessageID discord.MessageID
+	UserID    discord.UserID
+	GuildID   discord.GuildID
+	Content   string
+	Flags     *discord.MessageFlags
+	Emoji     discord.APIEmoji
+	Response  *api.InteractionResponse
+}
+
+func (c Call) String() string {
+	switch c.Method {
+	case "Message":
+		return fmt.Sprintf("fetch message %d in #%d", c.MessageID, c.ChannelID)
+	case "SendMessage":
+		return fmt.Sprintf("send message %d in #%d: %q", c.MessageID, c.ChannelID, c.Content)
+	case "EditMessage":
+		s := fmt.Sprintf("edit message %d in #%d", c.MessageID, c.ChannelID)
+		if c.Flags != nil && *c.Flags&discord.SuppressEmbeds != 0 {
+			s += ", suppress embeds"
+		}
+		if c.Content != "" {
+			s += fmt.Sprintf(": %q", c.Content)
+		}
+		return s
+	case "DeleteMessage":
+		return fmt.Sprintf("delete message %d in #%d", c.MessageID, c.ChannelID)
+	case "React":
+		return fmt.Sprintf("react %s to message %d in #%d", c.Emoji, c.MessageID, c.ChannelID)
+	case "CreatePrivateChannel":
+		return fmt.Sprintf("open DM with %d", c.UserID)
+	case "RespondInteraction":
+		return fmt.Sprintf("respond: %q", c.Content)
+	case "EditInteractionResponse":
+		return fmt.Sprintf("edit response: %q", c.Content)
+	case "FollowUpInteraction":
+		return fmt.Sprintf("follow up: %q", c.Content)
+	case "CreateCommand", "EditCommand", "DeleteCommand":
+		return fmt.Sprintf("%s %s", c.Method, c.Content)
+	case "CreateGuildCommand", "EditGuildCommand", "DeleteGuildCommand":
+		return fmt.Sprintf("%s %s in guild %d", c.Method, c.Content, c.GuildID)
+	default:
+		return c.Method
+	}
+}
+
+// Discord records the calls made to it and keeps the messages it knows about in memory.
+type Discord struct {
+	mu       sync.Mutex
+	calls    []Call
+	me       discord.User
+	messages map[discord.MessageID]discord.Message
+	perms    map[discord.ChannelID]map[discord.UserID]discord.Permissions
+	// defaultPerms are the permissions of users not set with SetPermissions.
+	defaultPerms discord.Permissions
+	dms          map[discord.UserID]discord.ChannelID
+	channels     map[discord.GuildID][]discord.Channel
+	roles        map[discord.GuildID][]discord.Role
+	commands     map[discord.GuildID][]discord.Command
+	nextID       discord.Snowflake
+}
+
+// New creates a fake client logged in as me.
+func New(me discord.User) *Discord {
+	return &Discord{
+		me:       me,
+		messages: make(map[discord.MessageID]discord.Message),
+		perms:    make(map[discord.ChannelID]map[discord.UserID]discord.Permissions),
+		dms:      make(map[discord.UserID]discord.ChannelID),
+		commands: make(map[discord.GuildID][]discord.Command),
+		channels: make(map[discord.GuildID][]discord.Channel),
+		roles:    make(map[discord.GuildID][]discord.Role),
+		nextID:   1 << 40,
+	}
+}
+
+// AddMessage makes the message available to Message, e.g. after Discord has resolved its embeds.
+func (d *Discord) AddMessage(m discord.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages[m.ID] = m
+}
+
+// UpdateMessage merges the fields present in a partial message update into the known message.
+func (d *Discord) UpdateMessage(m discord.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	known, ok := d.messages[m.ID]
+	if !ok {
+		d.messages[m.ID] = m
+		return
+	}
+	if m.Content != "" {
+		known.Content = m.Content
+	}
+	if m.Embeds != nil {
+		known.Embeds = m.Embeds
+	}
+	if m.MessageSnapshots != nil {
+		known.MessageSnapshots = m.MessageSnapshots
+	}
+	if m.Flags != 0 {
+		known.Flags = m.Flags
+	}
+	d.messages[m.ID] = known
+}
+
+// SetPermissions sets the permissions of the user in the channel, users have no permissions by default.
+func (d *Discord) SetPermissions(channelID discord.ChannelID, userID discord.UserID, perms discord.Permissions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.perms[channelID] == nil {
+		d.perms[channelID] = make(map[discord.UserID]discord.Permissions)
+	}
+	d.perms[channelID][userID] = perms
+}
+
+// AddChannel makes the channel available to Channels of its guild.
+func (d *Discord) AddChannel(ch discord.Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels[ch.GuildID] = append(d.channels[ch.GuildID], ch)
+}
+
+// AddRole makes the role available to Roles of the guild.
+func (d *Discord) AddRole(guildID discord.GuildID, role discord.Role) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.roles[guildID] = append(d.roles[guildID], role)
+}
+
+// SetDefaultPermissions sets the permissions of users in channels they have no permissions set in.
+func (d *Discord) SetDefaultPermissions(perms discord.Permissions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.defaultPerms = perms
+}
+
+// Calls returns the calls made so far, optionally only the ones of the given methods.
+func (d *Discord) Calls(methods ...string) []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var calls []Call
+	for _, call := range d.calls {
+		if len(methods) == 0 {
+			calls = append(calls, call)
+			continue
+		}
+		for _, method := range methods {
+			if call.Method == method {
+				calls = append(calls, call)
+				break
+			}
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls.
+func (d *Discord) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = nil
+}
+
+// DMChannel returns the private channel created for the user, if any.
+func (d *Discord) DMChannel(userID discord.UserID) (discord.ChannelID, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, ok := d.dms[userID]
+	return id, ok
+}
+
+func (d *Discord) record(call Call) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+}
+
+func (d *Discord) newID() discord.Snowflake {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	return d.nextID
+}
+
+func (d *Discord) Me() (*discord.User, error) {
+	me := d.me
+	return &me, nil
+}
+
+func (d *Discord) Message(channelID discord.ChannelID, messageID discord.MessageID) (*discord.Message, error) {
+	d.record(Call{Method: "Message", ChannelID: channelID, MessageID: messageID})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, ok := d.messages[messageID]
+	if !ok || m.ChannelID != channelID {
+		return nil, fmt.Errorf("unknown message %d in #%d", messageID, channelID)
+	}
+	return &m, nil
+}
+
+func (d *Discord) SendMessage(channelID discord.ChannelID, content string, embeds ...discord.Embed) (*discord.Message, error) {
+	return d.SendMessageComplex(channelID, api.SendMessageData{
+		Content: content,
+		Embeds:  embeds,
+	})
+}
+
+func (d *Discord) SendMessageComplex(channelID discord.ChannelID, data api.SendMessageData) (*discord.Message, error) {
+	m := discord.Message{
+		ID:        discord.MessageID(d.newID()),
+		ChannelID: channelID,
+		Author:    d.me,
+		Content:   data.Content,
+		Embeds:    data.Embeds,
+		Flags:     data.Flags,
+		Reference: data.Reference,
+	}
+	d.AddMessage(m)
+
+	flags := data.Flags
+	d.record(Call{Method: "SendMessage", ChannelID: channelID, MessageID: m.ID, Content: data.Content, Flags: &flags})
+	return &m, nil
+}
+
+func (d *Discord) EditMessageComplex(channelID discord.ChannelID, messageID discord.MessageID, data api.EditMessageData) (*discord.Message, error) {
+	call := Call{Method: "EditMessage", ChannelID: channelID, MessageID: messageID, Flags: data.Flags}
+	if data.Content != nil {
+		call.Content = data.Content.Val
+	}
+	d.record(call)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, ok := d.messages[messageID]
+	if !ok {
+		m = discord.Message{ID: messageID, ChannelID: channelID}
+	}
+	if data.Content != nil {
+		m.Content = data.Content.Val
+	}
+	if data.Flags != nil {
+		m.Flags = *data.Flags
+	}
+	d.messages[messageID] = m
+	return &m, nil
+}
+
+func (d *Discord) DeleteMessage(channelID discord.ChannelID, messageID discord.MessageID, reason api.AuditLogReason) error {
+	d.record(Call{Method: "DeleteMessage", ChannelID: channelID, MessageID: messageID})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.messages, messageID)
+	return nil
+}
+
+func (d *Discord) React(channelID discord.ChannelID, messageID discord.MessageID, emoji discord.APIEmoji) error {
+	d.record(Call{Method: "React", ChannelID: channelID, MessageID: messageID, Emoji: emoji})
+	return nil
+}
+
+func (d *Discord) CreatePrivateChannel(recipientID discord.UserID) (*discord.Channel, error) {
+	d.record(Call{Method: "CreatePrivateChannel", UserID: recipientID})
+
+	id, ok := d.DMChannel(recipientID)
+	if !ok {
+		id = discord.ChannelID(d.newID())
+		d.mu.Lock()
+		d.dms[recipientID] = id
+		d.mu.Unlock()
+	}
+	return &discord.Channel{
+		ID:   id,
+		Type: discord.DirectMessage,
+	}, nil
+}
+
+func (d *Discord) RespondInteraction(id discord.InteractionID, token string, resp api.InteractionResponse) error {
+	call := Call{Method: "RespondInteraction", Response: &resp}
+	if resp.Data != nil {
+		if resp.Data.Content != nil {
+			call.Content = resp.Data.Content.Val
+		}
+		call.Flags = &resp.Data.Flags
+	}
+	d.record(call)
+	return nil
+}
+
+func (d *Discord) EditInteractionResponse(appID discord.AppID, token string, data api.EditInteractionResponseData) (*discord.Message, error) {
+	call := Call{Method: "EditInteractionResponse"}
+	if data.Content != nil {
+		call.Content = data.Content.Val
+	}
+	d.record(call)
+	return &discord.Message{Content: call.Content, Author: d.me}, nil
+}
+
+func (d *Discord) FollowUpInteraction(appID discord.AppID, token string, data api.InteractionResponseData) (*discord.Message, error) {
+	call := Call{Method: "FollowUpInteraction", Flags: &data.Flags}
+	if data.Content != nil {
+		call.Content = data.Content.Val
+	}
+	d.record(call)
+	return &discord.Message{Content: call.Content, Author: d.me, Flags: data.Flags}, nil
+}
+
+func (d *Discord) Permissions(channelID discord.ChannelID, userID discord.UserID) (discord.Permissions, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	perms, ok := d.perms[channelID][userID]
+	if !ok {
+		return d.defaultPerms, nil
+	}
+	return perms, nil
+}
+
+// Channels returns the channels added to the guild. Calls to it are not recorded.
+func (d *Discord) Channels(guildID discord.GuildID) ([]discord.Channel, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]discord.Channel(nil), d.channels[guildID]...), nil
+}
+
+// Roles returns the roles added to the guild. Calls to it are not recorded.
+func (d *Discord) Roles(guildID discord.GuildID) ([]discord.Role, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]discord.Role(nil), d.roles[guildID]...), nil
+}
+
+// newCommand round-trips the data through JSON, the way commands come back from Discord.
+func (d *Discord) newCommand(appID discord.AppID, id discord.CommandID, data api.CreateCommandData) discord.Command {
+	if data.Type == 0 {
+		data.Type = discord.ChatInputCommand
+	}
+	var cmd discord.Command
+	raw, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(raw, &cmd)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("command %s: %v", data.Name, err))
+	}
+	cmd.ID = id
+	cmd.AppID = appID
+	return cmd
+}
+
+// Commands returns the registered global commands. Calls to it are not recorded.
+func (d *Discord) Commands(appID discord.AppID) ([]discord.Command, error) {
+	return d.GuildCommands(appID, 0)
+}
+
+// CreateCommand registers the global command, replacing the one of the same type and name like Discord does.
+func (d *Discord) CreateCommand(appID discord.AppID, data api.CreateCommandData) (*discord.Command, error) {
+	d.record(Call{Method: "CreateCommand", Content: data.Name})
+	return d.createCommand(appID, 0, data)
+}
+
+func (d *Discord) EditCommand(appID discord.AppID, commandID discord.CommandID, data api.CreateCommandData) (*discord.Command, error) {
+	d.record(Call{Method: "EditCommand", Content: data.Name})
+	return d.editCommand(appID, 0, commandID, data)
+}
+
+func (d *Discord) DeleteCommand(appID discord.AppID, commandID discord.CommandID) error {
+	return d.deleteCommand("DeleteCommand", 0, commandID)
+}
+
+func (d *Discord) BulkOverwriteCommands(appID discord.AppID, commands []api.CreateCommandData) ([]discord.Command, error) {
+	d.record(Call{Method: "BulkOverwriteCommands"})
+	return d.overwriteCommands(appID, 0, commands)
+}
+
+// GuildCommands returns the commands registered in the guild. Calls to it are not recorded.
+func (d *Discord) GuildCommands(appID discord.AppID, guildID discord.GuildID) ([]discord.Command, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]discord.Command(nil), d.commands[guildID]...), nil
+}
+
+func (d *Discord) CreateGuildCommand(appID discord.AppID, guildID discord.GuildID, data api.CreateCommandData) (*discord.Command, error) {
+	d.record(Call{Method: "CreateGuildCommand", GuildID: guildID, Content: data.Name})
+	return d.createCommand(appID, guildID, data)
+}
+
+func (d *Discord) EditGuildCommand(appID discord.AppID, guildID discord.GuildID, commandID discord.CommandID, data api.CreateCommandData) (*discord.Command, error) {
+	d.record(Call{Method: "EditGuildCommand", GuildID: guildID, Content: data.Name})
+	return d.editCommand(appID, guildID, commandID, data)
+}
+
+func (d *Discord) DeleteGuildCommand(appID discord.AppID, guildID discord.GuildID, commandID discord.CommandID) error {
+	return d.deleteCommand("DeleteGuildCommand", guildID, commandID)
+}
+
+func (d *Discord) BulkOverwriteGuildCommands(appID discord.AppID, guildID discord.GuildID, commands []api.CreateCommandData) ([]discord.Command, error) {
+	d.record(Call{Method: "BulkOverwriteGuildCommands", GuildID: guildID})
+	return d.overwriteCommands(appID, guildID, commands)
+}
+
+// Commands are kept per guild, global commands under the zero guild ID.
+
+func (d *Discord) createCommand(appID discord.AppID, guildID discord.GuildID, data api.CreateCommandData) (*discord.Command, error) {
+	cmd := d.newCommand(appID, discord.CommandID(d.newID()), data)
+	cmd.GuildID = guildID
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cmds := d.commands[guildID]
+	for i, c := range cmds {
+		if c.Type == cmd.Type && c.Name == cmd.Name {
+			cmd.ID = c.ID
+			cmds[i] = cmd
+			return &cmd, nil
+		}
+	}
+	d.commands[guildID] = append(cmds, cmd)
+	return &cmd, nil
+}
+
+func (d *Discord) editCommand(appID discord.AppID, guildID discord.GuildID, commandID discord.CommandID, data api.CreateCommandData) (*discord.Command, error) {
+	cmd := d.newCommand(appID, commandID, data)
+	cmd.GuildID = guildID
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, c := range d.commands[guildID] {
+		if c.ID == commandID {
+			d.commands[guildID][i] = cmd
+			return &cmd, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown command %d", commandID)
+}
+
+func (d *Discord) deleteCommand(method string, guildID discord.GuildID, commandID discord.CommandID) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cmds := d.commands[guildID]
+	for i, c := range cmds {
+		if c.ID == commandID {
+			d.calls = append(d.calls, Call{Method: method, GuildID: guildID, Content: c.Name})
+			d.commands[guildID] = append(cmds[:i], cmds[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown command %d", commandID)
+}
+
+func (d *Discord) overwriteCommands(appID discord.AppID, guildID discord.GuildID, commands []api.CreateCommandData) ([]discord.Command, error) {
+	cmds := make([]discord.Command, len(commands))
+	for i, command := range commands {
+		cmds[i] = d.newCommand(appID, discord.CommandID(d.newID()), command)
+		cmds[i].GuildID = guildID
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.commands[guildID] = cmds
+	return append([]discord.Command(nil), cmds...), nil
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// defaultLanguage is the language of command descriptions, and of messages missing from other catalogues.
+const defaultLanguage = discord.ChineseTaiwan
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogues are the messages of each language, loaded from locales/<language>.json.
+var catalogues = loadCatalogues()
+
+func loadCatalogues() map[discord.Language]map[string]string {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	catalogues := make(map[discord.Language]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("locales/%s: %v", file.Name(), err))
+		}
+		catalogues[discord.Language(strings.TrimSuffix(file.Name(), ".json"))] = messages
+	}
+	return catalogues
+}
+
+// responseLanguages are the languages responses are made in, by preference. Their catalogues have every
+// message, the others only localize the commands.
+var responseLanguages = []discord.Language{defaultLanguage, discord.EnglishUS}
+
+// resolveLanguage picks the first locale that responses can be made in, falling back to close variants, e.g.
+// en-GB to en-US.
+func resolveLanguage(locales ...string) discord.Language {
+	for _, locale := range locales {
+		if locale == "" {
+			continue
+		}
+		if slices.Contains(responseLanguages, discord.Language(locale)) {
+			return discord.Language(locale)
+		}
+		prefix, _, _ := strings.Cut(locale, "-")
+		for _, language := range responseLanguages {
+			if p, _, _ := strings.Cut(string(language), "-"); p == prefix {
+				return language
+			}
+		}
+	}
+	return defaultLanguage
+}
+
+// tr formats the message of the key in the language, falling back to defaultLanguage.
+func tr(lang discord.Language, key string, args ...any) string {
+	msg, ok := catalogues[lang][key]
+	if !ok {
+		msg, ok = catalogues[defaultLanguage][key]
+	}
+	if !ok {
+		log.Printf("Missing message %s", key)
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// localizations collects the message of the key from every catalogue that has it.
+func localizations(key string) discord.StringLocales {
+	var locales discord.StringLocales
+	for lang, messages := range catalogues {
+		if msg, ok := messages[key]; ok {
+			if locales == nil {
+				locales = discord.StringLocales{}
+			}
+			locales[lang] = msg
+		}
+	}
+	return locales
+}
+
+// interactionLanguage is the language to respond the interaction in.
+func interactionLanguage(e *gateway.InteractionCreateEvent) discord.Language {
+	return resolveLanguage(string(e.Locale), e.GuildLocale)
+}
+
+// guildLanguage is the language of messages outside of interactions, which carry no locale.
+// It prefers the locale remembered for the user, then the one remembered for the guild.
+func (b *Bot) guildLanguage(guildID discord.GuildID, userLocale string) discord.Language {
+	guildLocale, err := b.storage.GetGuildLocale(uint64(guildID))
+	if err != nil {
+		log.Printf("Error getting locale of guild %d: %v", guildID, err)
+	}
+	return resolveLanguage(userLocale, guildLocale)
+}
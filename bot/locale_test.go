@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var verbRegex = regexp.MustCompile(`%(\[\d+\])?[a-z]`)
+
+// sampleArgs makes arguments matching the verbs of the message, to format its translations with.
+func sampleArgs(msg string) []any {
+	var args []any
+	for _, verb := range verbRegex.FindAllString(msg, -1) {
+		if strings.HasSuffix(verb, "d") {
+			args = append(args, 1)
+		} else {
+			args = append(args, "x")
+		}
+	}
+	return args
+}
+
+func TestCatalogues(t *testing.T) {
+	defaults := catalogues[defaultLanguage]
+	for _, lang := range responseLanguages {
+		for key := range defaults {
+			if _, ok := catalogues[lang][key]; !ok {
+				t.Errorf("Expected %s in %s", key, lang)
+			}
+		}
+	}
+
+	for lang, messages := range catalogues {
+		for key, msg := range messages {
+			def, ok := defaults[key]
+			if !ok {
+				t.Errorf("Expected %s of %s in %s", key, lang, defaultLanguage)
+				continue
+			}
+			if formatted := tr(lang, key, sampleArgs(def)...); strings.Contains(formatted, "%!") {
+				t.Errorf("Expected %s of %s to take the arguments of %s, got %q from %q", key, lang, defaultLanguage, formatted, msg)
+			}
+		}
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	tests := []struct {
+		locales  []string
+		expected discord.Language
+	}{
+		{nil, defaultLanguage},
+		{[]string{"en-US"}, discord.EnglishUS},
+		{[]string{"en-GB"}, discord.EnglishUS},
+		{[]string{"fr", "en-US"}, discord.EnglishUS},
+		// Catalogues only localizing the commands aren't used for responses
+		{[]string{"", "zh-CN"}, discord.ChineseTaiwan},
+		{[]string{"zh-HK"}, discord.ChineseTaiwan},
+		{[]string{"ja", "en-US"}, discord.EnglishUS},
+		{[]string{"fr"}, defaultLanguage},
+	}
+	for _, tt := range tests {
+		if got := resolveLanguage(tt.locales...); got != tt.expected {
+			t.Errorf("resolveLanguage(%q) = %s, expected %s", tt.locales, got, tt.expected)
+		}
+	}
+}
+
+func TestCommandsLocalized(t *testing.T) {
+	cmds := newCommandsBot(t).registry.CreateData()
+	for _, cmd := range cmds {
+		if cmd.Type == discord.MessageCommand {
+			if cmd.NameLocalizations[discord.EnglishUS] == "" {
+				t.Errorf("Expected %s to have an English name", cmd.Name)
+			}
+			continue
+		}
+		if cmd.Description == "" || strings.HasPrefix(cmd.Description, "cmd.") {
+			t.Errorf("Expected %s to have a description, got %q", cmd.Name, cmd.Description)
+		}
+		if cmd.DescriptionLocalizations[discord.EnglishUS] == "" {
+			t.Errorf("Expected %s to have an English description", cmd.Name)
+		}
+	}
+
+	var mode *discord.StringOption
+	for _, cmd := range cmds {
+		if cmd.Name == "embed_hints" {
+			mode = cmd.Options[0].(*discord.StringOption)
+		}
+	}
+	if mode.Choices[1].Name != "不要提示" || mode.Choices[1].NameLocalizations[discord.EnglishUS] != "Don't hint" {
+		t.Errorf("Expected localized choices, got %+v", mode.Choices[1])
+	}
+}
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// permissionKeys name the permissions the commands declare, in the order they are listed.
+var permissionKeys = []struct {
+	perm discord.Permissions
+	key  string
+}{
+	{discord.PermissionManageGuild, "permission.manage_guild"},
+	{discord.PermissionManageChannels, "permission.manage_channels"},
+	{discord.PermissionViewChannel, "permission.view_channel"},
+	{discord.PermissionManageMessages, "permission.manage_messages"},
+	{discord.PermissionAddReactions, "permission.add_reactions"},
+}
+
+// permissionNames lists the permissions in the language, unnamed ones by their bits.
+func permissionNames(lang discord.Language, perms discord.Permissions) string {
+	var names []string
+	for _, p := range permissionKeys {
+		if perms.Has(p.perm) {
+			names = append(names, tr(lang, p.key))
+			perms &^= p.perm
+		}
+	}
+	if perms != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint64(perms)))
+	}
+	return strings.Join(names, ", ")
+}
+
+// permissionsMiddleware enforces the permissions of the command at runtime, since guilds can override the
+// default permissions the commands are registered with. Members of the bot-admin roles of the guild can use
+// every command without its permissions unless it has StrictPermissions. The bot also needs BotPermissions.
+func (b *Bot) permissionsMiddleware(e *gateway.InteractionCreateEvent, state *InteractionHandlerState, next ...Middleware[InteractionHandlerState]) error {
+	cmd := state.Command
+	if cmd != nil && (cmd.Permissions != 0 || cmd.BotPermissions != 0) {
+		key, missing, err := b.missingPermissions(e, state)
+		if err != nil {
+			log.Printf("Error checking permissions of %s: %v", cmd.Data.Name, err)
+			key = "error.check_permissions"
+		}
+		if key != "" {
+			// Autocomplete can't respond with an error, suggest nothing instead
+			if _, ok := e.Data.(*discord.AutocompleteInteraction); ok {
+				return b.respondChoices(e, api.AutocompleteStringChoices{})
+			}
+			return b.RespondError(e, key, permissionNames(interactionLanguage(e), missing))
+		}
+	}
+	if len(next) > 0 {
+		return next[0](e, state, next[1:]...)
+	}
+	return nil
+}
+
+// missingPermissions returns the error to respond and the missing permissions if the command can't be used.
+// The permissions are the ones Discord resolved in the payload of the interaction.
+func (b *Bot) missingPermissions(e *gateway.InteractionCreateEvent, state *InteractionHandlerState) (string, discord.Permissions, error) {
+	cmd := state.Command
+	if cmd.Permissions != 0 {
+		if e.Member == nil {
+			return "error.missing_permissions", cmd.Permissions, nil
+		}
+		if perms := state.MemberPermissions; !perms.Has(cmd.Permissions) {
+			admin, err := b.isBotAdmin(e.GuildID, e.Member)
+			if err != nil {
+				return "", 0, err
+			}
+			if cmd.StrictPermissions || !admin {
+				return "error.missing_permissions", cmd.Permissions &^ perms, nil
+			}
+		}
+	}
+
+	if perms := state.AppPermissions; cmd.BotPermissions != 0 && !perms.Has(cmd.BotPermissions) {
+		return "error.bot_missing_permissions", cmd.BotPermissions &^ perms, nil
+	}
+	return "", 0, nil
+}
+
+// isBotAdmin reports whether the member has one of the bot-admin roles of the guild.
+func (b *Bot) isBotAdmin(guildID discord.GuildID, member *discord.Member) (bool, error) {
+	roleIDs, err := b.storage.GetBotAdminRoles(uint64(guildID))
+	if err != nil {
+		return false, err
+	}
+	for _, roleID := range member.RoleIDs {
+		if slices.Contains(roleIDs, uint64(roleID)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (b *Bot) respondBotAdminRoles(i *gateway.InteractionCreateEvent, msg string) error {
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleAddBotAdminRole(i *gateway.InteractionCreateEvent) error {
+	roleID, err := subcommandOptions(i.Data.(*discord.CommandInteraction)).Find("role").SnowflakeValue()
+	if err != nil {
+		return err
+	}
+	if err := b.storage.AddBotAdminRole(uint64(i.GuildID), uint64(roleID)); err != nil {
+		return err
+	}
+	return b.respondBotAdminRoles(i, tr(interactionLanguage(i), "bot_admin.added", roleID))
+}
+
+func (b *Bot) handleRemoveBotAdminRole(i *gateway.InteractionCreateEvent) error {
+	roleID, err := subcommandOptions(i.Data.(*discord.CommandInteraction)).Find("role").SnowflakeValue()
+	if err != nil {
+		return err
+	}
+	removed, err := b.storage.RemoveBotAdminRole(uint64(i.GuildID), uint64(roleID))
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return b.RespondError(i, "error.not_bot_admin", roleID)
+	}
+	return b.respondBotAdminRoles(i, tr(interactionLanguage(i), "bot_admin.removed", roleID))
+}
+
+func (b *Bot) handleListBotAdminRoles(i *gateway.InteractionCreateEvent) error {
+	roleIDs, err := b.storage.GetBotAdminRoles(uint64(i.GuildID))
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	if len(roleIDs) == 0 {
+		return b.respondBotAdminRoles(i, tr(lang, "bot_admin.none"))
+	}
+	sb := strings.Builder{}
+	sb.WriteString(tr(lang, "bot_admin.list") + "\n")
+	for _, roleID := range roleIDs {
+		sb.WriteString(fmt.Sprintf("-# - <@&%d>\n", roleID))
+	}
+	return b.respondBotAdminRoles(i, sb.String())
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// rateLimitCounts are the interactions allowed and limited by the rate limit, per command. They are served
+// with the other expvars on /debug/vars of the status server, see Config.StatusAddr.
+var (
+	rateLimitAllowed = expvar.NewMap("interactions_allowed")
+	rateLimitLimited = expvar.NewMap("interactions_rate_limited")
+)
+
+// rateLimitPruneSize is how many buckets are kept before idle ones are pruned.
+const rateLimitPruneSize = 1024
+
+type rateLimitKey struct {
+	userID  discord.UserID
+	command string
+}
+
+// rateLimiter limits how often each user can use each command.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[rateLimitKey]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[rateLimitKey]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the bucket of the user and command, the user can use the command burst times
+// at once and once more every period/burst. Otherwise it returns how long until the next token.
+func (l *rateLimiter) Allow(userID discord.UserID, command string, burst int, period time.Duration, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if burst <= 0 || period <= 0 {
+		return true, 0
+	}
+
+	key := rateLimitKey{userID, command}
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.capacity != float64(burst) || bucket.period != period {
+		if len(l.buckets) >= rateLimitPruneSize {
+			l.prune(now)
+		}
+		bucket = newTokenBucket(burst, period, now)
+		l.buckets[key] = bucket
+	}
+	if bucket.take(1, now) {
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) * float64(period) / bucket.capacity)
+}
+
+// prune drops the buckets that have been refilled, they are the same as new ones.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= bucket.period {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware limits how often a user can use a command and its components. Autocomplete isn't
+// limited since it is sent as the user types.
+func (b *Bot) rateLimitMiddleware(e *gateway.InteractionCreateEvent, state *InteractionHandlerState, next ...Middleware[InteractionHandlerState]) error {
+	cmd := state.Command
+	if _, ok := e.Data.(*discord.AutocompleteInteraction); cmd != nil && !ok {
+		name := cmd.Data.Name
+		allowed, wait := b.rateLimiter.Allow(e.SenderID(), name, b.config.RateLimitBurst, b.config.RateLimitPeriod, b.clock.Now())
+		if !allowed {
+			rateLimitLimited.Add(name, 1)
+			return b.RespondError(e, "error.rate_limited", int(math.Ceil(wait.Seconds())))
+		}
+		rateLimitAllowed.Add(name, 1)
+	}
+	if len(next) > 0 {
+		return next[0](e, state, next[1:]...)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+const (
+	recordMessageCreate     = "MESSAGE_CREATE"
+	recordMessageUpdate     = "MESSAGE_UPDATE"
+	recordInteractionCreate = "INTERACTION_CREATE"
+)
+
+// RecordedEvent is a line of a recording.
+type RecordedEvent struct {
+	At   time.Time       `json:"at"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Recorder writes received gateway events as newline-delimited JSON, see Replay.
+// Message content is redacted down to the links and user mentions the bot makes decisions on.
+type Recorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	enc   *json.Encoder
+	clock clock.Clock
+}
+
+func NewRecorder(path string, clk clock.Clock) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		f:     f,
+		enc:   json.NewEncoder(f),
+		clock: clk,
+	}, nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+func (r *Recorder) record(typ string, event any) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error recording %s: %v", typ, err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.enc.Encode(RecordedEvent{
+		At:   r.clock.Now(),
+		Type: typ,
+		Data: data,
+	})
+	if err != nil {
+		log.Printf("Error recording %s: %v", typ, err)
+	}
+}
+
+func (r *Recorder) handleMessageCreate(m *gateway.MessageCreateEvent) {
+	e := *m
+	redactMessage(&e.Message)
+	r.record(recordMessageCreate, &e)
+}
+
+func (r *Recorder) handleMessageUpdate(m *gateway.MessageUpdateEvent) {
+	e := *m
+	redactMessage(&e.Message)
+	r.record(recordMessageUpdate, &e)
+}
+
+func (r *Recorder) handleInteractionCreate(i *interactionCreateEvent) {
+	e := *i
+	// The token can be used to respond on behalf of the bot
+	e.Token = ""
+	if data, ok := e.Data.(*discord.CommandInteraction); ok && len(data.Resolved.Messages) > 0 {
+		d := *data
+		d.Resolved.Messages = make(map[discord.MessageID]discord.Message, len(data.Resolved.Messages))
+		for id, msg := range data.Resolved.Messages {
+			redactMessage(&msg)
+			d.Resolved.Messages[id] = msg
+		}
+		e.Data = &d
+	}
+	r.record(recordInteractionCreate, &e)
+}
+
+// redactedKeepRegex matches what redactContent keeps.
+var redactedKeepRegex = regexp.MustCompile(userMentionRegex.String() + "|" + linkRegex.String())
+
+// redactContent keeps only the links and user mentions of the content.
+func redactContent(content string) string {
+	return strings.Join(redactedKeepRegex.FindAllString(content, -1), " ")
+}
+
+// redactMessage redacts the content of the message and the messages it carries, in place.
+func redactMessage(m *discord.Message) {
+	m.Content = redactContent(m.Content)
+	if len(m.MessageSnapshots) > 0 {
+		snapshots := make([]discord.MessageSnapshot, len(m.MessageSnapshots))
+		for i, snapshot := range m.MessageSnapshots {
+			snapshot.Message.Content = redactContent(snapshot.Message.Content)
+			snapshots[i] = snapshot
+		}
+		m.MessageSnapshots = snapshots
+	}
+	if m.ReferencedMessage != nil {
+		ref := *m.ReferencedMessage
+		redactMessage(&ref)
+		m.ReferencedMessage = &ref
+	}
+}
@@ -0,0 +1,432 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// CommandHandler handles an invoked command or subcommand.
+type CommandHandler func(e *gateway.InteractionCreateEvent) error
+
+// Command declares an application command, how it is registered and how it is handled.
+type Command struct {
+	// Data is registered as is, except for the fields filled in by the registry:
+	// DefaultMemberPermissions, the options of subcommands, and descriptions from the catalogues.
+	Data api.CreateCommandData
+	// Permissions are the permissions members need to see and use the command, none if zero.
+	// They are registered as the default, which guilds can override, and enforced by permissionsMiddleware.
+	Permissions discord.Permissions
+	// StrictPermissions commands can't be used by members of the bot-admin roles without Permissions.
+	StrictPermissions bool
+	// BotPermissions are the permissions the bot needs in the channel for the command to take effect.
+	BotPermissions discord.Permissions
+	// Middlewares run before the handler, in order.
+	Middlewares []Middleware[InteractionHandlerState]
+	// Handler handles the command, commands with subcommands are handled by the subcommands instead.
+	Handler CommandHandler
+	// Subcommands are registered as the options of the command.
+	Subcommands []Subcommand
+	// Experimental commands are only registered in dev and experimental guilds.
+	Experimental bool
+	// Components handle the message components and modals the command responds with, by the name in their custom ID.
+	Components map[string]CommandHandler
+	// Autocomplete handles the autocompletion of options declared with Autocomplete, by the dotted path of the option
+	// below the command, e.g. "remove.id" for the id option of the remove subcommand.
+	Autocomplete map[string]CommandHandler
+}
+
+// Subcommand declares a subcommand, optionally in a subcommand group.
+type Subcommand struct {
+	// Group is the name of the subcommand group, empty for subcommands directly under the command.
+	Group   string
+	Option  discord.SubcommandOption
+	Handler CommandHandler
+}
+
+// CommandRegistry generates the registration data of the commands, and dispatches interactions to them.
+type CommandRegistry struct {
+	commands    []*Command
+	byName      map[string]*Command
+	middlewares []Middleware[InteractionHandlerState]
+}
+
+// NewCommandRegistry registers the commands, it panics if two commands share a name.
+func NewCommandRegistry(cmds ...*Command) *CommandRegistry {
+	r := &CommandRegistry{byName: make(map[string]*Command, len(cmds))}
+	for _, cmd := range cmds {
+		if _, ok := r.byName[cmd.Data.Name]; ok {
+			panic(fmt.Sprintf("command %s registered twice", cmd.Data.Name))
+		}
+		r.commands = append(r.commands, cmd)
+		r.byName[cmd.Data.Name] = cmd
+	}
+	return r
+}
+
+// Use adds middlewares run for every command, before the middlewares of the command.
+func (r *CommandRegistry) Use(middlewares ...Middleware[InteractionHandlerState]) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// CreateData is the registration data of all commands.
+func (r *CommandRegistry) CreateData() []api.CreateCommandData {
+	data := make([]api.CreateCommandData, len(r.commands))
+	for i, cmd := range r.commands {
+		data[i] = cmd.createData()
+	}
+	return localizeCommands(data)
+}
+
+// ScopeData is the registration data of the commands included in the scope.
+func (r *CommandRegistry) ScopeData(scope CommandScope) []api.CreateCommandData {
+	var data []api.CreateCommandData
+	for _, cmd := range r.commands {
+		if scope.includes(cmd) {
+			data = append(data, cmd.createData())
+		}
+	}
+	return localizeCommands(data)
+}
+
+func (c *Command) createData() api.CreateCommandData {
+	data := c.Data
+	if data.Type == 0 {
+		data.Type = discord.ChatInputCommand
+	}
+	if c.Permissions != 0 {
+		perms := c.Permissions
+		data.DefaultMemberPermissions = &perms
+	}
+	if len(c.Subcommands) == 0 {
+		return data
+	}
+
+	data.Options = nil
+	groups := make(map[string]*discord.SubcommandGroupOption)
+	for _, sub := range c.Subcommands {
+		option := sub.Option
+		if sub.Group == "" {
+			data.Options = append(data.Options, &option)
+			continue
+		}
+		group, ok := groups[sub.Group]
+		if !ok {
+			group = &discord.SubcommandGroupOption{OptionName: sub.Group}
+			groups[sub.Group] = group
+			data.Options = append(data.Options, group)
+		}
+		group.Subcommands = append(group.Subcommands, &option)
+	}
+	return data
+}
+
+// invokedSubcommand finds the subcommand group and subcommand of the interaction, if any.
+func invokedSubcommand(data *discord.CommandInteraction) (group, sub string) {
+	if len(data.Options) == 0 {
+		return "", ""
+	}
+	option := data.Options[0]
+	switch option.Type {
+	case discord.SubcommandGroupOptionType:
+		if len(option.Options) > 0 {
+			return option.Name, option.Options[0].Name
+		}
+		return option.Name, ""
+	case discord.SubcommandOptionType:
+		return "", option.Name
+	}
+	return "", ""
+}
+
+// subcommandOptions are the options given to the invoked subcommand, or to the command if it has no subcommands.
+func subcommandOptions(data *discord.CommandInteraction) discord.CommandInteractionOptions {
+	options := data.Options
+	for len(options) > 0 && (options[0].Type == discord.SubcommandGroupOptionType || options[0].Type == discord.SubcommandOptionType) {
+		options = options[0].Options
+	}
+	return options
+}
+
+func (c *Command) handler(data *discord.CommandInteraction) (CommandHandler, error) {
+	if len(c.Subcommands) == 0 {
+		return c.Handler, nil
+	}
+	group, name := invokedSubcommand(data)
+	for _, sub := range c.Subcommands {
+		if sub.Group == group && sub.Option.OptionName == name {
+			return sub.Handler, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown subcommand %q %q of %s", group, name, c.Data.Name)
+}
+
+// Dispatch runs the middlewares and the handler of the invoked command.
+func (r *CommandRegistry) Dispatch(e *gateway.InteractionCreateEvent, state *InteractionHandlerState) error {
+	data, ok := e.Data.(*discord.CommandInteraction)
+	if !ok {
+		return fmt.Errorf("not a command interaction: %T", e.Data)
+	}
+	cmd, ok := r.byName[data.Name]
+	if !ok {
+		return fmt.Errorf("unknown command %s", data.Name)
+	}
+	handler, err := cmd.handler(data)
+	if err != nil {
+		return err
+	}
+	return r.run(cmd, e, state, handler)
+}
+
+// customID is the custom ID of a component or modal of the command, with an optional argument like a role ID.
+func customID(command, name string, arg ...string) discord.ComponentID {
+	return discord.ComponentID(strings.Join(append([]string{command, name}, arg...), ":"))
+}
+
+// parseCustomID splits a custom ID made by customID.
+func parseCustomID(id discord.ComponentID) (command, name, arg string) {
+	command, rest, _ := strings.Cut(string(id), ":")
+	name, arg, _ = strings.Cut(rest, ":")
+	return command, name, arg
+}
+
+// interactionCustomID is the custom ID of the component or modal of the interaction.
+func interactionCustomID(e *gateway.InteractionCreateEvent) (discord.ComponentID, bool) {
+	switch data := e.Data.(type) {
+	case discord.ComponentInteraction:
+		return data.ID(), true
+	case *discord.ModalInteraction:
+		return data.CustomID, true
+	}
+	return "", false
+}
+
+// customIDArg is the argument in the custom ID of the component or modal of the interaction.
+func customIDArg(e *gateway.InteractionCreateEvent) string {
+	id, _ := interactionCustomID(e)
+	_, _, arg := parseCustomID(id)
+	return arg
+}
+
+// DispatchComponent runs the middlewares of the command owning the component or modal, and the handler of the component.
+func (r *CommandRegistry) DispatchComponent(e *gateway.InteractionCreateEvent, state *InteractionHandlerState) error {
+	id, ok := interactionCustomID(e)
+	if !ok {
+		return fmt.Errorf("not a component or modal interaction: %T", e.Data)
+	}
+	command, name, _ := parseCustomID(id)
+	cmd, ok := r.byName[command]
+	if !ok {
+		return fmt.Errorf("unknown command %s of component %s", command, id)
+	}
+	handler, ok := cmd.Components[name]
+	if !ok {
+		return fmt.Errorf("unknown component %s", id)
+	}
+	return r.run(cmd, e, state, handler)
+}
+
+func (r *CommandRegistry) run(cmd *Command, e *gateway.InteractionCreateEvent, state *InteractionHandlerState, handler CommandHandler) error {
+	state.Command = cmd
+	chain := make([]Middleware[InteractionHandlerState], 0, len(r.middlewares)+len(cmd.Middlewares)+1)
+	chain = append(chain, r.middlewares...)
+	chain = append(chain, cmd.Middlewares...)
+	chain = append(chain, func(e *gateway.InteractionCreateEvent, state *InteractionHandlerState, next ...Middleware[InteractionHandlerState]) error {
+		return handler(e)
+	})
+	return chain[0](e, state, chain[1:]...)
+}
+
+// focusedOption finds the option being autocompleted, and its dotted path below the command.
+func focusedOption(data *discord.AutocompleteInteraction) (string, discord.AutocompleteOption) {
+	var path []string
+	options := data.Options
+	for len(options) > 0 && (options[0].Type == discord.SubcommandGroupOptionType || options[0].Type == discord.SubcommandOptionType) {
+		path = append(path, options[0].Name)
+		options = options[0].Options
+	}
+	focused := options.Focused()
+	return strings.Join(append(path, focused.Name), "."), focused
+}
+
+// DispatchAutocomplete runs the middlewares of the command and the autocomplete handler of the focused option.
+func (r *CommandRegistry) DispatchAutocomplete(e *gateway.InteractionCreateEvent, state *InteractionHandlerState) error {
+	data, ok := e.Data.(*discord.AutocompleteInteraction)
+	if !ok {
+		return fmt.Errorf("not an autocomplete interaction: %T", e.Data)
+	}
+	cmd, ok := r.byName[data.Name]
+	if !ok {
+		return fmt.Errorf("unknown command %s", data.Name)
+	}
+	path, _ := focusedOption(data)
+	handler, ok := cmd.Autocomplete[path]
+	if !ok {
+		return fmt.Errorf("no autocomplete for %s of %s", path, data.Name)
+	}
+	return r.run(cmd, e, state, handler)
+}
+
+// CommandEdit is a live command whose registration data has changed.
+type CommandEdit struct {
+	ID   discord.CommandID
+	Data api.CreateCommandData
+}
+
+// CommandDiff is what has to be done for the live commands to match the registry.
+type CommandDiff struct {
+	Create    []api.CreateCommandData
+	Edit      []CommandEdit
+	Delete    []discord.Command
+	Unchanged int
+}
+
+// Empty reports whether the live commands already match.
+func (d CommandDiff) Empty() bool {
+	return len(d.Create) == 0 && len(d.Edit) == 0 && len(d.Delete) == 0
+}
+
+func (d CommandDiff) String() string {
+	return fmt.Sprintf("%d created, %d edited, %d deleted, %d unchanged", len(d.Create), len(d.Edit), len(d.Delete), d.Unchanged)
+}
+
+type commandKey struct {
+	Type discord.CommandType
+	Name string
+}
+
+// commandSpec are the fields of a command compared with the live one, the rest are assigned by Discord.
+type commandSpec struct {
+	Type                     discord.CommandType    `json:"type"`
+	Name                     string                 `json:"name"`
+	NameLocalizations        discord.StringLocales  `json:"name_localizations,omitempty"`
+	Description              string                 `json:"description"`
+	DescriptionLocalizations discord.StringLocales  `json:"description_localizations,omitempty"`
+	Options                  discord.CommandOptions `json:"options,omitempty"`
+	DefaultMemberPermissions *discord.Permissions   `json:"default_member_permissions,omitempty"`
+}
+
+func sameCommand(want api.CreateCommandData, live discord.Command) bool {
+	a, err := normalizedJSON(commandSpec{want.Type, want.Name, want.NameLocalizations, want.Description, want.DescriptionLocalizations, want.Options, want.DefaultMemberPermissions})
+	if err != nil {
+		return false
+	}
+	b, err := normalizedJSON(commandSpec{live.Type, live.Name, live.NameLocalizations, live.Description, live.DescriptionLocalizations, live.Options, live.DefaultMemberPermissions})
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
+
+// normalizedJSON marshals v without empty values, which Discord may either omit or send back.
+func normalizedJSON(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(dropEmpty(generic))
+}
+
+func dropEmpty(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			value = dropEmpty(value)
+			if isEmpty(value) {
+				delete(v, key)
+			} else {
+				v[key] = value
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = dropEmpty(value)
+		}
+	}
+	return v
+}
+
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// DiffCommands compares the wanted commands with the live ones by type and name.
+func DiffCommands(want []api.CreateCommandData, live []discord.Command) CommandDiff {
+	liveByKey := make(map[commandKey]discord.Command, len(live))
+	for _, cmd := range live {
+		liveByKey[commandKey{cmd.Type, cmd.Name}] = cmd
+	}
+
+	var diff CommandDiff
+	for _, data := range want {
+		key := commandKey{data.Type, data.Name}
+		cmd, ok := liveByKey[key]
+		delete(liveByKey, key)
+		switch {
+		case !ok:
+			diff.Create = append(diff.Create, data)
+		case !sameCommand(data, cmd):
+			diff.Edit = append(diff.Edit, CommandEdit{ID: cmd.ID, Data: data})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for _, cmd := range live {
+		if _, ok := liveByKey[commandKey{cmd.Type, cmd.Name}]; ok {
+			diff.Delete = append(diff.Delete, cmd)
+		}
+	}
+	return diff
+}
+
+// Sync creates, edits and deletes the commands of the scope that differ from the registry, leaving the rest untouched.
+func (r *CommandRegistry) Sync(client Discord, appID discord.AppID, scope CommandScope) (CommandDiff, error) {
+	sc := scopedClient{client, appID, scope.GuildID}
+	live, err := sc.commands()
+	if err != nil {
+		return CommandDiff{}, err
+	}
+
+	diff := DiffCommands(r.ScopeData(scope), live)
+	for _, data := range diff.Create {
+		if err := sc.create(data); err != nil {
+			return diff, fmt.Errorf("creating command %s: %w", data.Name, err)
+		}
+		log.Printf("Created %s command %s", scope, data.Name)
+	}
+	for _, edit := range diff.Edit {
+		if err := sc.edit(edit.ID, edit.Data); err != nil {
+			return diff, fmt.Errorf("editing command %s: %w", edit.Data.Name, err)
+		}
+		log.Printf("Edited %s command %s", scope, edit.Data.Name)
+	}
+	for _, cmd := range diff.Delete {
+		if err := sc.delete(cmd.ID); err != nil {
+			return diff, fmt.Errorf("deleting command %s: %w", cmd.Name, err)
+		}
+		log.Printf("Deleted %s command %s", scope, cmd.Name)
+	}
+	return diff, nil
+}
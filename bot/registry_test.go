@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/No3371/dc_embed_throttler/bot/internal/fakediscord"
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// newCommandsBot creates a bot only to take its commands from.
+func newCommandsBot(t *testing.T) *Bot {
+	t.Helper()
+	b, err := newBot(&config.Config{}, nil, clock.System, fakediscord.New(discord.User{ID: 1, Bot: true}))
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	return b
+}
+
+func TestCommandRegistryDispatch(t *testing.T) {
+	var called []string
+	handler := func(name string) CommandHandler {
+		return func(e *gateway.InteractionCreateEvent) error {
+			called = append(called, name)
+			return nil
+		}
+	}
+	middleware := func(e *gateway.InteractionCreateEvent, state *InteractionHandlerState, next ...Middleware[InteractionHandlerState]) error {
+		called = append(called, "middleware")
+		return next[0](e, state, next[1:]...)
+	}
+	r := NewCommandRegistry(
+		&Command{
+			Data:        api.CreateCommandData{Name: "plain"},
+			Middlewares: []Middleware[InteractionHandlerState]{middleware},
+			Handler:     handler("plain"),
+		},
+		&Command{
+			Data: api.CreateCommandData{Name: "nested"},
+			Subcommands: []Subcommand{
+				{Option: discord.SubcommandOption{OptionName: "top"}, Handler: handler("top")},
+				{Group: "group", Option: discord.SubcommandOption{OptionName: "a"}, Handler: handler("group a")},
+				{Group: "group", Option: discord.SubcommandOption{OptionName: "b"}, Handler: handler("group b")},
+			},
+		},
+	)
+
+	value, _ := json.Marshal(3)
+	tests := []struct {
+		data     *discord.CommandInteraction
+		expected []string
+	}{
+		{&discord.CommandInteraction{Name: "plain"}, []string{"middleware", "plain"}},
+		{&discord.CommandInteraction{Name: "nested", Options: []discord.CommandInteractionOption{
+			{Type: discord.SubcommandOptionType, Name: "top"},
+		}}, []string{"top"}},
+		{&discord.CommandInteraction{Name: "nested", Options: []discord.CommandInteractionOption{
+			{Type: discord.SubcommandGroupOptionType, Name: "group", Options: []discord.CommandInteractionOption{
+				{Type: discord.SubcommandOptionType, Name: "b", Options: []discord.CommandInteractionOption{
+					{Type: discord.IntegerOptionType, Name: "n", Value: value},
+				}},
+			}},
+		}}, []string{"group b"}},
+	}
+	for _, tt := range tests {
+		called = nil
+		e := &gateway.InteractionCreateEvent{InteractionEvent: discord.InteractionEvent{Data: tt.data}}
+		if err := r.Dispatch(e, &InteractionHandlerState{}); err != nil {
+			t.Fatalf("Failed to dispatch %s: %v", tt.data.Name, err)
+		}
+		if fmt.Sprint(called) != fmt.Sprint(tt.expected) {
+			t.Errorf("Expected %q, got %q", tt.expected, called)
+		}
+	}
+	if n, err := subcommandOptions(tests[2].data).Find("n").IntValue(); err != nil || n != 3 {
+		t.Errorf("Expected the options of the subcommand, got %d, %v", n, err)
+	}
+
+	for _, data := range []*discord.CommandInteraction{
+		{Name: "unknown"},
+		{Name: "nested", Options: []discord.CommandInteractionOption{{Type: discord.SubcommandOptionType, Name: "a"}}},
+	} {
+		e := &gateway.InteractionCreateEvent{InteractionEvent: discord.InteractionEvent{Data: data}}
+		if err := r.Dispatch(e, &InteractionHandlerState{}); err == nil {
+			t.Errorf("Expected an error dispatching %+v", data)
+		}
+	}
+
+	created := r.CreateData()[1]
+	if len(created.Options) != 2 {
+		t.Fatalf("Expected a subcommand and a group, got %+v", created.Options)
+	}
+	if group, ok := created.Options[1].(*discord.SubcommandGroupOption); !ok || len(group.Subcommands) != 2 {
+		t.Errorf("Expected a group of 2 subcommands, got %+v", created.Options[1])
+	}
+}
+
+func TestCommandRegistrySync(t *testing.T) {
+	fake := fakediscord.New(discord.User{ID: 1, Bot: true})
+	appID := discord.AppID(1)
+	registry := func() *CommandRegistry {
+		return NewCommandRegistry(newCommandsBot(t).commands()...)
+	}
+
+	// A leftover command to be deleted
+	fake.CreateCommand(appID, api.CreateCommandData{Name: "restore_embeds", Description: "old"})
+	fake.Reset()
+
+	diff, err := registry().Sync(fake, appID, CommandScope{Stable: true})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	total := len(registry().CreateData())
+	if len(diff.Create) != total || len(diff.Delete) != 1 || len(fake.Calls("DeleteCommand")) != 1 {
+		t.Fatalf("Expected %d creations and 1 deletion, got %s", total, diff)
+	}
+
+	fake.Reset()
+	diff, err = registry().Sync(fake, appID, CommandScope{Stable: true})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if !diff.Empty() || diff.Unchanged != total || len(fake.Calls()) != 0 {
+		t.Fatalf("Expected no changes, got %s and %v", diff, fake.Calls())
+	}
+
+	r := registry()
+	r.byName["my_quota"].Permissions = discord.PermissionManageChannels
+	diff, err = r.Sync(fake, appID, CommandScope{Stable: true})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	edits := fake.Calls("EditCommand")
+	if len(diff.Edit) != 1 || len(edits) != 1 || edits[0].Content != "my_quota" {
+		t.Fatalf("Expected my_quota to be edited, got %s and %v", diff, fake.Calls())
+	}
+}
+
+func TestCommandScopes(t *testing.T) {
+	fake := fakediscord.New(discord.User{ID: 1, Bot: true})
+	appID := discord.AppID(1)
+	registry := NewCommandRegistry(
+		&Command{Data: api.CreateCommandData{Name: "stable"}},
+		&Command{Data: api.CreateCommandData{Name: "experimental"}, Experimental: true},
+	)
+
+	cfg := &config.Config{ExperimentalGuilds: []uint64{10}}
+	scopes := CommandScopes(cfg)
+	if fmt.Sprint(scopes) != "[global guild 10]" {
+		t.Fatalf("Expected global and guild 10, got %v", scopes)
+	}
+	for _, scope := range scopes {
+		if _, err := registry.Sync(fake, appID, scope); err != nil {
+			t.Fatalf("Failed to sync %s: %v", scope, err)
+		}
+	}
+	global, _ := fake.Commands(appID)
+	experimental, _ := fake.GuildCommands(appID, 10)
+	if len(global) != 1 || global[0].Name != "stable" || len(experimental) != 1 || experimental[0].Name != "experimental" {
+		t.Fatalf("Expected stable commands globally and experimental ones in guild 10, got %v and %v", global, experimental)
+	}
+
+	// Dev guilds get every command, and the global commands are left alone
+	cfg.DevGuilds = []uint64{20}
+	scopes = CommandScopes(cfg)
+	if len(scopes) != 1 || !scopes[0].Stable || !scopes[0].Experimental {
+		t.Fatalf("Expected only the dev guild with all commands, got %+v", scopes)
+	}
+	fake.Reset()
+	if _, err := registry.Sync(fake, appID, scopes[0]); err != nil {
+		t.Fatalf("Failed to sync %s: %v", scopes[0], err)
+	}
+	if creates := fake.Calls("CreateGuildCommand"); len(creates) != 2 || len(fake.Calls()) != 2 {
+		t.Fatalf("Expected 2 commands created in the dev guild, got %v", fake.Calls())
+	}
+
+	var out strings.Builder
+	if err := manageCommands(&out, fake, appID, registry, "diff", GuildScope(cfg, 10), nil); err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+	if !strings.Contains(out.String(), "- /experimental") {
+		t.Errorf("Expected the experimental command to be deleted from the no longer opted-in guild, got %q", out.String())
+	}
+	fake.Reset()
+	if err := manageCommands(&out, fake, appID, registry, "delete", GuildScope(cfg, 20), []string{"stable"}); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if deletes := fake.Calls("DeleteGuildCommand"); len(deletes) != 1 || deletes[0].Content != "stable" {
+		t.Errorf("Expected stable to be deleted, got %v", fake.Calls())
+	}
+	if err := manageCommands(&out, fake, appID, registry, "delete", GuildScope(cfg, 20), []string{"unknown"}); err == nil {
+		t.Errorf("Expected an error deleting an unknown command")
+	}
+}
+
+func TestCommandAutocompleteOptions(t *testing.T) {
+	// Every autocomplete handler must be for an option registered with Autocomplete, or Discord never asks for it
+	for _, cmd := range newCommandsBot(t).commands() {
+		for path := range cmd.Autocomplete {
+			options := cmd.createData().Options
+			names := strings.Split(path, ".")
+			var autocomplete bool
+			for _, name := range names {
+				var next discord.CommandOptions
+				for _, option := range options {
+					if option.Name() != name {
+						continue
+					}
+					switch option := option.(type) {
+					case *discord.SubcommandGroupOption:
+						for _, sub := range option.Subcommands {
+							next = append(next, sub)
+						}
+					case *discord.SubcommandOption:
+						for _, value := range option.Options {
+							next = append(next, value)
+						}
+					case *discord.StringOption:
+						autocomplete = option.Autocomplete
+					case *discord.IntegerOption:
+						autocomplete = option.Autocomplete
+					case *discord.NumberOption:
+						autocomplete = option.Autocomplete
+					}
+				}
+				options = next
+			}
+			if !autocomplete {
+				t.Errorf("Expected %s of %s to be registered with Autocomplete", path, cmd.Data.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/bot/internal/fakediscord"
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+type deferredMessage struct {
+	event *gateway.MessageCreateEvent
+	at    time.Time
+}
+
+// replayer feeds recorded events through a bot talking to a fake Discord client and an in-memory database.
+// The recording does not carry channel settings, so every channel is treated as enabled.
+type replayer struct {
+	bot     *Bot
+	store   *storage.SQLiteStorage
+	fake    *fakediscord.Discord
+	clock   *clock.Fake
+	pending []deferredMessage
+
+	// before is called before a message is handled, the message is skipped if it returns false.
+	before func(m *gateway.MessageCreateEvent) bool
+	// report is called with the calls the bot made for every event, m is set for messages.
+	report func(at time.Time, header string, m *gateway.MessageCreateEvent, calls []fakediscord.Call)
+}
+
+// replays counts the replayers created, to name their databases.
+var replays atomic.Int64
+
+func newReplayer(cfg *config.Config) (*replayer, error) {
+	clk := clock.NewFake(time.Time{})
+	// Every replay has its own database
+	dsn := fmt.Sprintf("file:replay%d?mode=memory&cache=shared", replays.Add(1))
+	store, err := storage.NewSQLiteStorage(dsn, clk)
+	if err != nil {
+		return nil, err
+	}
+
+	replayCfg := *cfg
+	replayCfg.DefaultEnabled = true
+	fake := fakediscord.New(discord.User{Username: "replay", Bot: true})
+	fake.SetDefaultPermissions(discord.PermissionAll)
+	b, err := newBot(&replayCfg, store, clk, fake)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return &replayer{
+		bot:    b,
+		store:  store,
+		fake:   fake,
+		clock:  clk,
+		before: func(*gateway.MessageCreateEvent) bool { return true },
+		report: func(time.Time, string, *gateway.MessageCreateEvent, []fakediscord.Call) {},
+	}, nil
+}
+
+func (r *replayer) Close() error {
+	return r.store.Close()
+}
+
+func (r *replayer) reportCalls(at time.Time, header string, m *gateway.MessageCreateEvent) {
+	r.report(at, header, m, r.fake.Calls())
+	r.fake.Reset()
+}
+
+// flush fetches the deferred messages due by until, like LateSupressLoop would have.
+func (r *replayer) flush(until time.Time) {
+	for len(r.pending) > 0 && !r.pending[0].at.After(until) {
+		d := r.pending[0]
+		r.pending = r.pending[1:]
+		if d.at.After(r.clock.Now()) {
+			r.clock.Set(d.at)
+		}
+		r.bot.lateSuppress(d.event)
+		r.reportCalls(d.at, fmt.Sprintf("DEFERRED message %d in #%d by %d", d.event.ID, d.event.ChannelID, d.event.Author.ID), d.event)
+	}
+}
+
+func (r *replayer) run(rd io.Reader) error {
+	dec := json.NewDecoder(rd)
+	for {
+		var ev RecordedEvent
+		err := dec.Decode(&ev)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		r.flush(ev.At)
+		r.clock.Set(ev.At)
+
+		var header string
+		var message *gateway.MessageCreateEvent
+		switch ev.Type {
+		case recordMessageCreate:
+			var m gateway.MessageCreateEvent
+			if err := json.Unmarshal(ev.Data, &m); err != nil {
+				return fmt.Errorf("decoding %s at %s: %w", ev.Type, ev.At, err)
+			}
+			r.fake.AddMessage(m.Message)
+			if !r.before(&m) {
+				continue
+			}
+			r.bot.handleMessageCreate(&m)
+			for len(r.bot.deferred) > 0 {
+				mv := <-r.bot.deferred
+				r.pending = append(r.pending, deferredMessage{event: mv, at: mv.Timestamp.Time().Add(deferDelay(&mv.Message))})
+			}
+			sort.SliceStable(r.pending, func(i, j int) bool { return r.pending[i].at.Before(r.pending[j].at) })
+			header = fmt.Sprintf("message %d in #%d by %d", m.ID, m.ChannelID, m.Author.ID)
+			message = &m
+		case recordMessageUpdate:
+			var m gateway.MessageUpdateEvent
+			if err := json.Unmarshal(ev.Data, &m); err != nil {
+				return fmt.Errorf("decoding %s at %s: %w", ev.Type, ev.At, err)
+			}
+			// The bot only learns about updates when it fetches the message
+			r.fake.UpdateMessage(m.Message)
+			header = fmt.Sprintf("message %d in #%d", m.ID, m.ChannelID)
+		case recordInteractionCreate:
+			var i interactionCreateEvent
+			if err := json.Unmarshal(ev.Data, &i); err != nil {
+				return fmt.Errorf("decoding %s at %s: %w", ev.Type, ev.At, err)
+			}
+			r.bot.handleInteractionCreate(&i)
+			header = fmt.Sprintf("interaction %d in #%d by %d", i.ID, i.ChannelID, i.SenderID())
+		default:
+			header = "unknown event"
+		}
+		r.reportCalls(ev.At, fmt.Sprintf("%s %s", ev.Type, header), message)
+	}
+
+	if len(r.pending) > 0 {
+		r.flush(r.pending[len(r.pending)-1].at)
+	}
+	return nil
+}
+
+// Replay feeds a recording made by Recorder through the bot, and writes what it would have done for every event to w.
+func Replay(rd io.Reader, w io.Writer, cfg *config.Config) error {
+	r, err := newReplayer(cfg)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	r.report = func(at time.Time, header string, m *gateway.MessageCreateEvent, calls []fakediscord.Call) {
+		fmt.Fprintf(w, "%s %s\n", at.Format(time.RFC3339Nano), header)
+		if len(calls) == 0 {
+			fmt.Fprintln(w, "  (no action)")
+		}
+		for _, call := range calls {
+			fmt.Fprintf(w, "  %s\n", call)
+		}
+	}
+	return r.run(rd)
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+func TestRedactContent(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"hello world", ""},
+		{"look at this https://example.com/a?b=c !!", "https://example.com/a?b=c"},
+		{"<@123> 轉貼 https://x.com/a <https://y.com>", "<@123> https://x.com/a <https://y.com>"},
+		{"secret(https://example.com)", "https://example.com)"},
+	}
+	for _, tt := range tests {
+		if got := redactContent(tt.content); got != tt.expected {
+			t.Errorf("redactContent(%q) = %q, expected %q", tt.content, got, tt.expected)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	recorder, err := NewRecorder(path, clk)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	author := discord.User{ID: testUserID}
+	for i := 1; i <= 4; i++ {
+		clk.Advance(time.Second)
+		recorder.handleMessageCreate(&gateway.MessageCreateEvent{
+			Message: discord.Message{
+				ID:        discord.MessageID(i),
+				ChannelID: testChannelID,
+				GuildID:   testGuildID,
+				Author:    author,
+				Content:   "private words https://example.com",
+				Embeds:    []discord.Embed{linkEmbed("Example")},
+				Timestamp: discord.NewTimestamp(clk.Now()),
+			},
+			Member: &discord.Member{User: author},
+		})
+	}
+	// Embeds resolved after the message has been posted
+	clk.Advance(time.Second)
+	recorder.handleMessageCreate(&gateway.MessageCreateEvent{
+		Message: discord.Message{
+			ID:        5,
+			ChannelID: testChannelID,
+			GuildID:   testGuildID,
+			Author:    author,
+			Content:   "https://example.com/late",
+			Timestamp: discord.NewTimestamp(clk.Now()),
+		},
+		Member: &discord.Member{User: author},
+	})
+	clk.Advance(100 * time.Millisecond)
+	recorder.handleMessageUpdate(&gateway.MessageUpdateEvent{
+		Message: discord.Message{
+			ID:        5,
+			ChannelID: testChannelID,
+			Embeds:    []discord.Embed{linkEmbed("Example")},
+		},
+	})
+	clk.Advance(time.Second)
+	recorder.handleInteractionCreate(&interactionCreateEvent{
+		InteractionCreateEvent: gateway.InteractionCreateEvent{
+			InteractionEvent: discord.InteractionEvent{
+				ID:        1,
+				Token:     "secret token",
+				ChannelID: testChannelID,
+				GuildID:   testGuildID,
+				Member:    &discord.Member{User: author},
+				Data:      &discord.CommandInteraction{Name: "my_quota"},
+			},
+		},
+		AppPermissions: discord.PermissionAll,
+	})
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	recording, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	if bytes.Contains(recording, []byte("private words")) || bytes.Contains(recording, []byte("secret token")) {
+		t.Fatalf("Expected recording to be redacted, got %s", recording)
+	}
+
+	out := bytes.Buffer{}
+	err = Replay(bytes.NewReader(recording), &out, &config.Config{DefaultQuota: 3})
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+
+	expected := []string{
+		"edit message 4 in #20, suppress embeds",
+		"DEFERRED message 5 in #20 by 30",
+		"edit message 5 in #20, suppress embeds",
+		`respond: "-# ✅ 於此頻道展開額度：0/3`,
+	}
+	for _, s := range expected {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Expected replay output to contain %q, got:\n%s", s, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "edit message 3 in") {
+		t.Errorf("Expected message 3 to be within quota, got:\n%s", out.String())
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	recorder, err := NewRecorder(path, clk)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	id := discord.MessageID(0)
+	for _, userID := range []discord.UserID{30, 31} {
+		member := &discord.Member{User: discord.User{ID: userID}}
+		if userID == 31 {
+			member.RoleIDs = []discord.RoleID{7}
+		}
+		for i := 0; i < 5; i++ {
+			id++
+			clk.Advance(time.Second)
+			recorder.handleMessageCreate(&gateway.MessageCreateEvent{
+				Message: discord.Message{
+					ID:        id,
+					ChannelID: testChannelID,
+					GuildID:   testGuildID,
+					Author:    member.User,
+					Content:   "https://example.com",
+					Embeds:    []discord.Embed{linkEmbed("Example")},
+					Timestamp: discord.NewTimestamp(clk.Now()),
+				},
+				Member: member,
+			})
+		}
+	}
+	recorder.Close()
+
+	tests := []struct {
+		name     string
+		h        Hypothesis
+		expected string
+	}{
+		{
+			name:     "default quota",
+			h:        Hypothesis{DefaultQuota: 3},
+			expected: "10 messages, 4 suppressed, 2 of 2 users affected",
+		},
+		{
+			name:     "role quota",
+			h:        Hypothesis{DefaultQuota: 3, RoleQuotas: []RoleQuota{{RoleID: 7, Quota: 10, Priority: 1}}},
+			expected: "10 messages, 2 suppressed, 1 of 2 users affected",
+		},
+		{
+			name:     "exempted role",
+			h:        Hypothesis{DefaultQuota: 4, ExemptRoles: []discord.RoleID{7}},
+			expected: "10 messages, 1 suppressed, 1 of 2 users affected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open recording: %v", err)
+			}
+			defer f.Close()
+
+			out := bytes.Buffer{}
+			err = Simulate(f, &out, &config.Config{DefaultQuota: 5}, tt.h)
+			if err != nil {
+				t.Fatalf("Failed to simulate: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.expected) {
+				t.Errorf("Expected %q, got:\n%s", tt.expected, out.String())
+			}
+			if !strings.Contains(out.String(), "2025-01-01") {
+				t.Errorf("Expected per day counts, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestReplayersAreIndependent(t *testing.T) {
+	a, err := newReplayer(&config.Config{DefaultQuota: 3})
+	if err != nil {
+		t.Fatalf("Failed to create replayer: %v", err)
+	}
+	defer a.Close()
+	b, err := newReplayer(&config.Config{DefaultQuota: 3})
+	if err != nil {
+		t.Fatalf("Failed to create replayer: %v", err)
+	}
+	defer b.Close()
+
+	if err := a.store.SetAttachmentQuota(uint64(testChannelID), 1); err != nil {
+		t.Fatalf("Failed to set attachment quota: %v", err)
+	}
+	if quota, err := b.store.GetAttachmentQuota(uint64(testChannelID)); err != nil || quota == 1 {
+		t.Fatalf("Expected the replayers not to share a database, got %d (%v)", quota, err)
+	}
+	if a.bot.deferred == b.bot.deferred {
+		t.Fatalf("Expected the replayers not to share deferred messages")
+	}
+}
@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// Links wrapped in <> are not embedded by Discord, they are matched only to be skipped.
+var linkRegex = regexp.MustCompile(`<?https?://[^\s<>]+>?`)
+
+// findLinks returns the links of the content that Discord would embed, without the punctuation and markdown closing them.
+func findLinks(content string) []string {
+	var links []string
+	for _, match := range linkRegex.FindAllString(content, -1) {
+		if strings.HasPrefix(match, "<") {
+			continue
+		}
+		links = append(links, trimLink(match))
+	}
+	return links
+}
+
+// trimLink strips the trailing punctuation and markdown of a link, closing parentheses are kept while balanced,
+// as in https://en.wikipedia.org/wiki/Go_(programming_language).
+func trimLink(link string) string {
+	for {
+		trimmed := strings.TrimRight(link, ".,;:!?*~|")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == link {
+			return link
+		}
+		link = trimmed
+	}
+}
+
+type rewrittenLink struct {
+	// Host is the normalized host of the original link.
+	Host      string
+	Original  string
+	Rewritten string
+}
+
+// normalizeHost turns user input like "https://www.X.com/" into "x.com".
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+// matchLinkRewrite finds the rewrite of the host or of any of its parent domains.
+func matchLinkRewrite(host string, rewrites []storage.LinkRewrite) (storage.LinkRewrite, bool) {
+	for _, rewrite := range rewrites {
+		if host == rewrite.FromHost || strings.HasSuffix(host, "."+rewrite.FromHost) {
+			return rewrite, true
+		}
+	}
+	return storage.LinkRewrite{}, false
+}
+
+func rewriteLinks(content string, rewrites []storage.LinkRewrite) []rewrittenLink {
+	if len(rewrites) == 0 {
+		return nil
+	}
+
+	var links []rewrittenLink
+	for _, match := range findLinks(content) {
+		u, err := url.Parse(match)
+		if err != nil {
+			continue
+		}
+		host := normalizeHost(u.Hostname())
+		rewrite, ok := matchLinkRewrite(host, rewrites)
+		if !ok {
+			continue
+		}
+
+		u.Host = rewrite.ToHost
+		links = append(links, rewrittenLink{
+			Host:      host,
+			Original:  match,
+			Rewritten: u.String(),
+		})
+	}
+	return links
+}
+
+// rewrittenCost charges the embeds the rewritten links are going to get. Every native embed of the message is
+// suppressed when the links are rewritten, so only the rewritten links are charged.
+func rewrittenCost(links []rewrittenLink, costs []storage.EmbedCost) int {
+	return len(links) * embedCostOf(discord.Embed{Type: discord.NormalEmbed}, costs)
+}
+
+// findLinkRewrites returns the links of the message that have rewrites configured in the guild.
+// Messages from bots are never rewritten.
+func (b *Bot) findLinkRewrites(m *gateway.MessageCreateEvent) []rewrittenLink {
+	if m.Author.Bot || !m.GuildID.IsValid() {
+		return nil
+	}
+
+	rewrites, err := b.storage.GetLinkRewrites(uint64(m.GuildID))
+	if err != nil {
+		log.Printf("Error getting link rewrites: %v", err)
+		return nil
+	}
+	return rewriteLinks(m.Content, rewrites)
+}
+
+// postRewrittenLinks suppresses the native embeds and replies with the rewritten links so they get embedded instead.
+func (b *Bot) postRewrittenLinks(m *discord.Message, links []rewrittenLink) {
+	err := b.suppressEmbeds(m)
+	if err != nil {
+		log.Printf("Error suppressing embeds for %d: %v", m.ID, err)
+		return
+	}
+
+	sb := strings.Builder{}
+	for _, link := range links {
+		sb.WriteString(link.Rewritten)
+		sb.WriteString("\n")
+	}
+
+	err = b.replyTracked(m, sb.String(), 0)
+	if err != nil {
+		log.Printf("Error posting rewritten links for %d: %v", m.ID, err)
+		return
+	}
+	log.Printf("Rewrote %d links of %d in #%d", len(links), m.ID, m.ChannelID)
+}
+
+// handleMessageDelete cleans up the replies posted for the deleted message.
+func (b *Bot) handleMessageDelete(e *gateway.MessageDeleteEvent) {
+	b.deleteBotReply(e.ChannelID, e.ID)
+}
+
+func (b *Bot) handleMessageDeleteBulk(e *gateway.MessageDeleteBulkEvent) {
+	for _, id := range e.IDs {
+		b.deleteBotReply(e.ChannelID, id)
+	}
+}
+
+func (b *Bot) deleteBotReply(channelID discord.ChannelID, messageID discord.MessageID) {
+	replyID, err := b.storage.DeleteBotReply(uint64(messageID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting reply of %d: %v", messageID, err)
+		return
+	}
+
+	err = b.client.DeleteMessage(channelID, discord.MessageID(replyID), "")
+	if err != nil {
+		log.Printf("Error deleting reply %d: %v", replyID, err)
+	}
+}
+
+func (b *Bot) handleSetLinkRewrite(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	from := normalizeHost(data.Options.Find("from").String())
+	to := normalizeHost(data.Options.Find("to").String())
+	if from == "" || to == "" || from == to {
+		return b.RespondError(i, "error.invalid_host")
+	}
+
+	err := b.storage.SetLinkRewrite(uint64(i.GuildID), from, to)
+	if err != nil {
+		return err
+	}
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(tr(interactionLanguage(i), "rewrite.set", from, to)),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleRemoveLinkRewrite(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	from := normalizeHost(data.Options.Find("from").String())
+
+	err := b.storage.RemoveLinkRewrite(uint64(i.GuildID), from)
+	if err != nil {
+		return err
+	}
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(tr(interactionLanguage(i), "rewrite.removed", from)),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleListLinkRewrites(i *gateway.InteractionCreateEvent) error {
+	rewrites, err := b.storage.GetLinkRewrites(uint64(i.GuildID))
+	if err != nil {
+		return err
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(tr(interactionLanguage(i), "rewrite.list") + "\n")
+	for _, rewrite := range rewrites {
+		sb.WriteString(fmt.Sprintf("-# - `%s` → `%s`\n", rewrite.FromHost, rewrite.ToHost))
+	}
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(sb.String()),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	for input, expected := range map[string]string{
+		"x.com":                   "x.com",
+		"https://www.X.com/":      "x.com",
+		" http://twitter.com/a/b": "twitter.com",
+		"tiktok.com?x=1":          "tiktok.com",
+	} {
+		if host := normalizeHost(input); host != expected {
+			t.Errorf("normalizeHost(%q): expected %q, got %q", input, expected, host)
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	rewrites := []storage.LinkRewrite{
+		{FromHost: "x.com", ToHost: "fixupx.com"},
+		{FromHost: "twitter.com", ToHost: "fxtwitter.com"},
+	}
+
+	links := rewriteLinks("look https://x.com/a/status/1?s=20 and https://mobile.twitter.com/b/status/2 "+
+		"but not <https://x.com/c/status/3> or https://example.com or https://notx.com/d", rewrites)
+
+	expected := []rewrittenLink{
+		{Host: "x.com", Original: "https://x.com/a/status/1?s=20", Rewritten: "https://fixupx.com/a/status/1?s=20"},
+		{Host: "mobile.twitter.com", Original: "https://mobile.twitter.com/b/status/2", Rewritten: "https://fxtwitter.com/b/status/2"},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, links)
+	}
+	for i := range expected {
+		if links[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], links[i])
+		}
+	}
+
+	if links := rewriteLinks("https://x.com/a", nil); links != nil {
+		t.Errorf("Expected no rewrites without rewrite rules, got %v", links)
+	}
+
+	// Punctuation and markdown closing the links are left out
+	links = rewriteLinks("(see https://x.com/a/status/1), **https://x.com/b/status/2**, https://x.com/c/status/(3).", rewrites)
+	expected = []rewrittenLink{
+		{Host: "x.com", Original: "https://x.com/a/status/1", Rewritten: "https://fixupx.com/a/status/1"},
+		{Host: "x.com", Original: "https://x.com/b/status/2", Rewritten: "https://fixupx.com/b/status/2"},
+		{Host: "x.com", Original: "https://x.com/c/status/(3)", Rewritten: "https://fixupx.com/c/status/(3)"},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, links)
+	}
+	for i := range expected {
+		if links[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], links[i])
+		}
+	}
+}
+
+func TestTrimLink(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/a":        "https://example.com/a",
+		"https://example.com/a.":       "https://example.com/a",
+		"https://example.com/a?!":      "https://example.com/a",
+		"https://example.com/a),":      "https://example.com/a",
+		"https://example.com/a**":      "https://example.com/a",
+		"https://example.com/a_(b)":    "https://example.com/a_(b)",
+		"https://example.com/a_(b)).":  "https://example.com/a_(b)",
+		"https://example.com/a?b=1&c=": "https://example.com/a?b=1&c=",
+	}
+	for link, expected := range tests {
+		if trimmed := trimLink(link); trimmed != expected {
+			t.Errorf("Expected %q to be trimmed to %q, got %q", link, expected, trimmed)
+		}
+	}
+}
+
+func TestRewrittenCost(t *testing.T) {
+	costs := []storage.EmbedCost{
+		{EmbedType: string(discord.VideoEmbed), Cost: 3},
+	}
+	links := []rewrittenLink{
+		{Host: "x.com", Original: "https://x.com/a", Rewritten: "https://fixupx.com/a"},
+	}
+
+	// The native embeds, the YouTube video besides the x.com link included, are all suppressed
+	// and replaced by a rich embed of the rewritten link.
+	if cost := rewrittenCost(links, costs); cost != 1 {
+		t.Errorf("Expected cost 1, got %d", cost)
+	}
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"log"
+	"slices"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// quotaChannelTypes are the channels role quotas are copied to, the ones members post embeds in.
+var quotaChannelTypes = []discord.ChannelType{
+	discord.GuildText,
+	discord.GuildAnnouncement,
+	discord.GuildForum,
+	discord.GuildVoice,
+	discord.GuildStageVoice,
+}
+
+// canManageChannel reports whether the sender can change the settings of the target channel, the way
+// permissionsMiddleware checks the channel the command is used in.
+func (b *Bot) canManageChannel(i *gateway.InteractionCreateEvent, channelID discord.ChannelID) (bool, error) {
+	if i.Member == nil {
+		return false, nil
+	}
+	perms, err := b.client.Permissions(channelID, i.Member.User.ID)
+	if err != nil {
+		return false, err
+	}
+	if perms.Has(discord.PermissionManageChannels) {
+		return true, nil
+	}
+	return b.isBotAdmin(i.GuildID, i.Member)
+}
+
+func (b *Bot) respondRoleQuota(i *gateway.InteractionCreateEvent, msg string) error {
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleRemoveRoleQuota(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	roleID, err := discord.ParseSnowflake(data.Options.Find("role").String())
+	if err != nil {
+		return b.RespondError(i, "error.invalid_role")
+	}
+
+	removed, err := b.storage.RemoveRoleQuota(uint64(i.ChannelID), uint64(roleID))
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return b.RespondError(i, "error.role_quota_not_found", roleID)
+	}
+	return b.respondRoleQuota(i, tr(interactionLanguage(i), "role_quota.removed", roleID))
+}
+
+func (b *Bot) handleCopyRoleQuotas(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	from, err := data.Options.Find("from").SnowflakeValue()
+	if err != nil {
+		return err
+	}
+	to, err := data.Options.Find("to").SnowflakeValue()
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return b.RespondError(i, "error.same_channel")
+	}
+	if ok, err := b.canManageChannel(i, discord.ChannelID(to)); err != nil {
+		return err
+	} else if !ok {
+		return b.RespondError(i, "error.missing_target_permissions", to, permissionNames(interactionLanguage(i), discord.PermissionManageChannels))
+	}
+
+	n, err := b.storage.CopyRoleQuotas(uint64(from), []uint64{uint64(to)})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return b.RespondError(i, "error.no_role_quotas", from)
+	}
+	return b.respondRoleQuota(i, tr(interactionLanguage(i), "role_quota.copied", from, n, to))
+}
+
+func (b *Bot) handleApplyRoleQuotas(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	from, err := data.Options.Find("from").SnowflakeValue()
+	if err != nil {
+		return err
+	}
+	category, err := data.Options.Find("category").SnowflakeValue()
+	if err != nil {
+		return err
+	}
+
+	channels, err := b.client.Channels(i.GuildID)
+	if err != nil {
+		return b.RespondError(i, "error.discord")
+	}
+	var channelIDs []uint64
+	// The channels the sender can't manage are skipped
+	skipped := 0
+	for _, ch := range channels {
+		if ch.ParentID != discord.ChannelID(category) || ch.ID == discord.ChannelID(from) || !slices.Contains(quotaChannelTypes, ch.Type) {
+			continue
+		}
+		ok, err := b.canManageChannel(i, ch.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+		channelIDs = append(channelIDs, uint64(ch.ID))
+	}
+	if len(channelIDs) == 0 && skipped > 0 {
+		return b.RespondError(i, "error.missing_target_permissions", category, permissionNames(interactionLanguage(i), discord.PermissionManageChannels))
+	}
+	if len(channelIDs) == 0 {
+		return b.RespondError(i, "error.empty_category", category)
+	}
+
+	n, err := b.storage.CopyRoleQuotas(uint64(from), channelIDs)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return b.RespondError(i, "error.no_role_quotas", from)
+	}
+	return b.respondRoleQuota(i, tr(interactionLanguage(i), "role_quota.applied", from, n, category, len(channelIDs)))
+}
+
+// autocompleteRoleQuotas suggests the roles with quotas in the channel, by their names if known.
+func (b *Bot) autocompleteRoleQuotas(i *gateway.InteractionCreateEvent) error {
+	quotas, err := b.storage.GetAllRoleQuotas(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+	names := make(map[discord.RoleID]string)
+	// Without the roles, their IDs are suggested instead
+	roles, err := b.client.Roles(i.GuildID)
+	if err != nil {
+		log.Printf("Error getting roles of %d: %v", i.GuildID, err)
+	}
+	for _, role := range roles {
+		names[role.ID] = role.Name
+	}
+
+	lang := interactionLanguage(i)
+	input := autocompleteInput(i)
+	choices := make(api.AutocompleteStringChoices, 0, len(quotas))
+	for _, quota := range quotas {
+		roleID := discord.RoleID(quota.RoleID)
+		name, ok := names[roleID]
+		if !ok {
+			name = roleID.String()
+		}
+		name = tr(lang, "role_quota.choice", name, quota.Quota, quota.Priority)
+		if len(choices) < maxChoices && matchesInput(name, input) {
+			choices = append(choices, discord.StringChoice{Name: name, Value: roleID.String()})
+		}
+	}
+	return b.respondChoices(i, choices)
+}
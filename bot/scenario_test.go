@@ -0,0 +1,681 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/bot/internal/fakediscord"
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+const (
+	testGuildID   discord.GuildID   = 10
+	testChannelID discord.ChannelID = 20
+	testUserID    discord.UserID    = 30
+)
+
+type scenario struct {
+	t       *testing.T
+	bot     *Bot
+	discord *fakediscord.Discord
+	clock   *clock.Fake
+	nextID  discord.MessageID
+	// locale is the locale of the interactions of testUserID.
+	locale discord.Language
+	// roles are the roles of testUserID in its interactions.
+	roles []discord.RoleID
+}
+
+// newScenario creates a bot talking to a fake Discord, with throttling enabled in testChannelID and a quota of 3.
+func newScenario(t *testing.T) *scenario {
+	t.Helper()
+	clk := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	store, err := storage.NewSQLiteStorage("file:"+t.Name()+"?mode=memory&cache=shared", clk)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if err := store.SetChannelEnabled(uint64(testChannelID), true); err != nil {
+		t.Fatalf("Failed to enable channel: %v", err)
+	}
+
+	fake := fakediscord.New(discord.User{ID: 1, Username: "throttler", Bot: true})
+	// Everyone can use every command unless a test takes permissions away
+	fake.SetDefaultPermissions(discord.PermissionAll)
+	b, err := newBot(&config.Config{DefaultQuota: 3}, store, clk, fake)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	return &scenario{t: t, bot: b, discord: fake, clock: clk, nextID: 100}
+}
+
+// post feeds a message with the embeds Discord has already resolved.
+func (s *scenario) post(content string, embeds ...discord.Embed) discord.Message {
+	s.nextID++
+	m := discord.Message{
+		ID:        s.nextID,
+		ChannelID: testChannelID,
+		GuildID:   testGuildID,
+		Author:    discord.User{ID: testUserID},
+		Content:   content,
+		Embeds:    embeds,
+		Timestamp: discord.NewTimestamp(s.clock.Now()),
+	}
+	s.discord.AddMessage(m)
+	s.bot.handleMessageCreate(&gateway.MessageCreateEvent{
+		Message: m,
+		Member:  &discord.Member{User: m.Author},
+	})
+	return m
+}
+
+// command feeds a slash command or message command invoked by testUserID.
+func (s *scenario) command(name string, target *discord.Message, options ...discord.CommandInteractionOption) {
+	data := &discord.CommandInteraction{Name: name, Options: options}
+	if target != nil {
+		data.TargetID = discord.Snowflake(target.ID)
+		data.Resolved.Messages = map[discord.MessageID]discord.Message{target.ID: *target}
+	}
+	s.interact(data)
+}
+
+// interact feeds an interaction of testUserID, e.g. with a component of a previous response. The payload
+// carries the permissions set on the fake, like Discord resolves them.
+func (s *scenario) interact(data discord.InteractionData) {
+	memberPerms, _ := s.discord.Permissions(testChannelID, testUserID)
+	appPerms, _ := s.discord.Permissions(testChannelID, 1)
+	s.bot.handleInteractionCreate(&interactionCreateEvent{
+		InteractionCreateEvent: gateway.InteractionCreateEvent{
+			InteractionEvent: discord.InteractionEvent{
+				ID:        1,
+				Token:     s.t.Name(),
+				ChannelID: testChannelID,
+				GuildID:   testGuildID,
+				Member:    &discord.Member{User: discord.User{ID: testUserID}, RoleIDs: s.roles},
+				Data:      data,
+				Locale:    s.locale,
+			},
+		},
+		MemberPermissions: memberPerms,
+		AppPermissions:    appPerms,
+	})
+}
+
+func stringOption(name, value string) discord.CommandInteractionOption {
+	raw, _ := json.Marshal(value)
+	return discord.CommandInteractionOption{Type: discord.StringOptionType, Name: name, Value: raw}
+}
+
+func (s *scenario) expectCalls(method string, n int) []fakediscord.Call {
+	s.t.Helper()
+	calls := s.discord.Calls(method)
+	if len(calls) != n {
+		s.t.Fatalf("Expected %d %s calls, got %d: %+v", n, method, len(calls), calls)
+	}
+	return calls
+}
+
+func (s *scenario) expectResponse(content string) {
+	s.t.Helper()
+	calls := s.expectCalls("RespondInteraction", 1)
+	if !strings.Contains(calls[0].Content, content) {
+		s.t.Fatalf("Expected response to contain %q, got %q", content, calls[0].Content)
+	}
+	s.discord.Reset()
+}
+
+func TestScenarioQuotaExhaustion(t *testing.T) {
+	s := newScenario(t)
+
+	for i := 0; i < 3; i++ {
+		s.post("https://example.com", linkEmbed("Example"))
+	}
+	s.expectCalls("EditMessage", 0)
+
+	over := s.post("https://example.com/4", linkEmbed("Example"))
+	edits := s.expectCalls("EditMessage", 1)
+	if edits[0].MessageID != over.ID || edits[0].Flags == nil || *edits[0].Flags&discord.SuppressEmbeds == 0 {
+		t.Fatalf("Expected embeds of %d to be suppressed, got %+v", over.ID, edits[0])
+	}
+	reacts := s.expectCalls("React", 1)
+	if reacts[0].MessageID != over.ID {
+		t.Fatalf("Expected reaction on %d, got %+v", over.ID, reacts[0])
+	}
+
+	dm, ok := s.discord.DMChannel(testUserID)
+	if !ok {
+		t.Fatal("Expected a DM channel to be opened")
+	}
+	sends := s.expectCalls("SendMessage", 1)
+	if sends[0].ChannelID != dm || !strings.Contains(sends[0].Content, "24 小時內不會再收到此提示") {
+		t.Fatalf("Expected quota hint in DM, got %+v", sends[0])
+	}
+
+	// The hint is only sent once per cooldown
+	s.discord.Reset()
+	s.post("https://example.com/5", linkEmbed("Example"))
+	s.expectCalls("EditMessage", 1)
+	s.expectCalls("SendMessage", 0)
+
+	s.discord.Reset()
+	s.command("my_quota", nil)
+	s.expectResponse("0/3")
+}
+
+func TestScenarioReclaimQuota(t *testing.T) {
+	s := newScenario(t)
+
+	m := s.post("https://example.com", linkEmbed("Example"))
+	s.expectCalls("EditMessage", 0)
+
+	s.command("suppress_embeds", &m)
+	edits := s.expectCalls("EditMessage", 1)
+	if edits[0].MessageID != m.ID || *edits[0].Flags&discord.SuppressEmbeds == 0 {
+		t.Fatalf("Expected embeds of %d to be suppressed, got %+v", m.ID, edits[0])
+	}
+	s.expectResponse("3/3")
+
+	// Too late to reclaim
+	m = s.post("https://example.com/2", linkEmbed("Example"))
+	s.clock.Advance(2 * time.Minute)
+	s.command("suppress_embeds", &m)
+	s.expectCalls("EditMessage", 0)
+	s.expectResponse("無法在一分鐘後回收額度")
+}
+
+func TestScenarioDisabledChannel(t *testing.T) {
+	s := newScenario(t)
+	if err := s.bot.storage.SetChannelEnabled(uint64(testChannelID), false); err != nil {
+		t.Fatalf("Failed to disable channel: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		s.post("https://example.com", linkEmbed("Example"))
+	}
+	if calls := s.discord.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected no calls in a disabled channel, got %+v", calls)
+	}
+}
+
+func TestScenarioLinkRewriteCleanup(t *testing.T) {
+	s := newScenario(t)
+	if err := s.bot.storage.SetLinkRewrite(uint64(testGuildID), "x.com", "fixupx.com"); err != nil {
+		t.Fatalf("Failed to set link rewrite: %v", err)
+	}
+
+	embed := linkEmbed("X")
+	embed.URL = "https://x.com/user/status/1"
+	m := s.post("https://x.com/user/status/1", embed)
+
+	s.expectCalls("EditMessage", 1)
+	sends := s.expectCalls("SendMessage", 1)
+	if !strings.Contains(sends[0].Content, "https://fixupx.com/user/status/1") {
+		t.Fatalf("Expected rewritten link, got %q", sends[0].Content)
+	}
+
+	s.bot.handleMessageDelete(&gateway.MessageDeleteEvent{ID: m.ID, ChannelID: m.ChannelID, GuildID: m.GuildID})
+	deletes := s.expectCalls("DeleteMessage", 1)
+	if deletes[0].MessageID != sends[0].MessageID {
+		t.Fatalf("Expected reply %d to be deleted, got %+v", sends[0].MessageID, deletes[0])
+	}
+}
+
+func TestScenarioOwnRepliesIgnored(t *testing.T) {
+	s := newScenario(t)
+	if err := s.bot.storage.SetLinkRewrite(uint64(testGuildID), "x.com", "fixupx.com"); err != nil {
+		t.Fatalf("Failed to set link rewrite: %v", err)
+	}
+
+	embed := linkEmbed("X")
+	embed.URL = "https://x.com/user/status/1"
+	s.post("https://x.com/user/status/1", embed)
+	send := s.expectCalls("SendMessage", 1)[0]
+	reply, err := s.discord.Message(testChannelID, send.MessageID)
+	if err != nil {
+		t.Fatalf("Failed to get the reply: %v", err)
+	}
+	s.discord.Reset()
+
+	// The reply gets the embed of the rewritten link, more times than the quota allows
+	reply.GuildID = testGuildID
+	reply.Embeds = []discord.Embed{{Type: discord.VideoEmbed, URL: "https://fixupx.com/user/status/1"}}
+	for i := 0; i < 4; i++ {
+		m := *reply
+		m.ID += discord.MessageID(i)
+		s.bot.handleMessageCreate(&gateway.MessageCreateEvent{
+			Message: m,
+			Member:  &discord.Member{User: m.Author},
+		})
+	}
+	if calls := s.discord.Calls("EditMessage", "React", "SendMessage"); len(calls) != 0 {
+		t.Fatalf("Expected the replies of the bot to be ignored, got %+v", calls)
+	}
+}
+
+func TestScenarioShadowMode(t *testing.T) {
+	s := newScenario(t)
+	if err := s.bot.storage.SetChannelEnabled(uint64(testChannelID), false); err != nil {
+		t.Fatalf("Failed to disable channel: %v", err)
+	}
+	s.command("toggle_shadow", nil)
+	s.expectResponse("**啟用**影子模式")
+
+	for i := 0; i < 5; i++ {
+		s.post(fmt.Sprintf("https://example.com/%d", i), linkEmbed("Example"))
+	}
+	if calls := s.discord.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected no calls in shadow mode, got %+v", calls)
+	}
+
+	s.command("shadow_report", nil)
+	s.expectResponse("允許展開：3 則\n-# - 額度不足抑制：2 則")
+
+	// Live throttling is overridden by shadow mode
+	if err := s.bot.storage.SetChannelEnabled(uint64(testChannelID), true); err != nil {
+		t.Fatalf("Failed to enable channel: %v", err)
+	}
+	s.post("https://example.com/6", linkEmbed("Example"))
+	if calls := s.discord.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected no calls in shadow mode, got %+v", calls)
+	}
+
+	// The quotas of the users aren't spent in shadow mode
+	s.command("toggle_shadow", nil)
+	s.expectResponse("**停用**影子模式")
+	s.command("my_quota", nil)
+	s.expectResponse("3/3")
+	for i := 0; i < 3; i++ {
+		s.post(fmt.Sprintf("https://example.com/live/%d", i), linkEmbed("Example"))
+	}
+	s.expectCalls("EditMessage", 0)
+	s.post("https://example.com/7", linkEmbed("Example"))
+	s.expectCalls("EditMessage", 1)
+}
+
+func TestScenarioShadowAttachments(t *testing.T) {
+	s := newScenario(t)
+	if err := s.bot.storage.SetAttachmentQuota(uint64(testChannelID), 1); err != nil {
+		t.Fatalf("Failed to set attachment quota: %v", err)
+	}
+	s.command("toggle_shadow", nil)
+	s.expectResponse("**啟用**影子模式")
+
+	m := discord.Message{
+		ID:          s.nextID + 1,
+		ChannelID:   testChannelID,
+		GuildID:     testGuildID,
+		Author:      discord.User{ID: testUserID},
+		Attachments: []discord.Attachment{{ID: 1}, {ID: 2}},
+		Timestamp:   discord.NewTimestamp(s.clock.Now()),
+	}
+	s.discord.AddMessage(m)
+	s.bot.handleMessageCreate(&gateway.MessageCreateEvent{Message: m, Member: &discord.Member{User: m.Author}})
+	if calls := s.discord.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected no calls in shadow mode, got %+v", calls)
+	}
+
+	s.command("shadow_report", nil)
+	s.expectResponse("附件額度不足刪除：1 則")
+	if usage, err := s.bot.storage.GetAttachmentUsage(uint64(testUserID), uint64(testChannelID)); err == nil && usage != 0 {
+		t.Fatalf("Expected the live attachment usage to be untouched, got %d", usage)
+	}
+}
+
+func TestScenarioHintPreferences(t *testing.T) {
+	s := newScenario(t)
+	for i := 0; i < 3; i++ {
+		s.post(fmt.Sprintf("https://example.com/%d", i), linkEmbed("Example"))
+	}
+
+	s.command("embed_hints", nil, stringOption("mode", "off"))
+	s.expectResponse("**不再**通知您")
+	s.post("https://example.com/off", linkEmbed("Example"))
+	s.expectCalls("EditMessage", 1)
+	s.expectCalls("CreatePrivateChannel", 0)
+	s.expectCalls("SendMessage", 0)
+
+	// Opted out users are not put on cooldown
+	s.discord.Reset()
+	s.command("embed_hints", nil, stringOption("mode", "channel"))
+	s.expectResponse("**頻道中**")
+	m := s.post("https://example.com/channel", linkEmbed("Example"))
+	sends := s.expectCalls("SendMessage", 1)
+	if sends[0].ChannelID != testChannelID || !strings.Contains(sends[0].Content, "24 小時內不會再收到此提示") {
+		t.Fatalf("Expected hint in channel, got %+v", sends[0])
+	}
+	if reply, err := s.discord.Message(testChannelID, sends[0].MessageID); err != nil || reply.Reference == nil || reply.Reference.MessageID != m.ID {
+		t.Fatalf("Expected hint to reply to %d, got %+v, %v", m.ID, reply, err)
+	}
+}
+
+func TestScenarioHintLocaleAndTemplate(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUK
+	s.command("set_hint_template", nil, stringOption("reason", "burst"), stringOption("template", `{user}, slow down in {channel}\nback in {hours}h`))
+	s.expectResponse("<@30>, slow down in <#20>\nback in 24h")
+
+	for i := 0; i < 4; i++ {
+		s.post(fmt.Sprintf("https://example.com/%d", i), linkEmbed("Example"))
+	}
+	sends := s.expectCalls("SendMessage", 1)
+	if !strings.Contains(sends[0].Content, "You won't receive this hint again in 24 hours") {
+		t.Fatalf("Expected hint in English, got %q", sends[0].Content)
+	}
+
+	// The guild's template is used regardless of the locale
+	s.discord.Reset()
+	if err := s.bot.storage.SetBurstLimit(uint64(testChannelID), 1, time.Hour); err != nil {
+		t.Fatalf("Failed to set burst limit: %v", err)
+	}
+	s.clock.Advance(hintCooldown(1))
+	s.bot.storage.ResetQuotaUsage(uint64(testUserID), uint64(testChannelID))
+	s.post("https://example.com/a", linkEmbed("Example"))
+	s.post("https://example.com/b", linkEmbed("Example"))
+	sends = s.expectCalls("SendMessage", 1)
+	if sends[0].Content != "<@30>, slow down in <#20>\nback in 48h" {
+		t.Fatalf("Expected hint from template, got %q", sends[0].Content)
+	}
+}
+
+func TestScenarioRememberLocales(t *testing.T) {
+	s := newScenario(t)
+	expectLocale := func(locale string) {
+		t.Helper()
+		user, err := s.bot.storage.GetUser(uint64(testUserID))
+		if err != nil || user.Locale != locale {
+			t.Fatalf("Expected locale %q to be stored, got %q (%v)", locale, user.Locale, err)
+		}
+	}
+
+	s.locale = discord.EnglishUS
+	s.command("my_quota", nil)
+	expectLocale("en-US")
+
+	// Unchanged locales aren't written again
+	if err := s.bot.storage.SetUserLocale(uint64(testUserID), "ja"); err != nil {
+		t.Fatalf("Failed to set locale: %v", err)
+	}
+	s.command("my_quota", nil)
+	expectLocale("ja")
+
+	// Neither are the locales of autocompletes
+	s.locale = discord.ChineseTaiwan
+	s.interact(&discord.AutocompleteInteraction{Name: "remove_link_rewrite"})
+	expectLocale("ja")
+
+	s.command("my_quota", nil)
+	expectLocale("zh-TW")
+}
+
+func TestScenarioEnglishResponses(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUS
+
+	s.command("my_quota", nil)
+	s.expectResponse("Embed quota in this channel: 3/3")
+
+	s.command("set_timezone", nil, stringOption("timezone", "Nowhere/City"))
+	s.expectResponse("❌ Invalid timezone: Nowhere/City")
+
+	for i := 0; i < 4; i++ {
+		s.post(fmt.Sprintf("https://example.com/%d", i), linkEmbed("Example"))
+	}
+	sends := s.expectCalls("SendMessage", 1)
+	if !strings.Contains(sends[0].Content, "Embed throttling is enabled in <#20>") {
+		t.Fatalf("Expected hint in the remembered locale, got %q", sends[0].Content)
+	}
+}
+
+func TestScenarioSettingsPanel(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUS
+
+	expectPanel := func(typ api.InteractionResponseType, contents ...string) {
+		t.Helper()
+		calls := s.expectCalls("RespondInteraction", 1)
+		if calls[0].Response.Type != typ || calls[0].Response.Data.Components == nil {
+			t.Fatalf("Expected the panel with components, got %+v", calls[0].Response)
+		}
+		for _, content := range contents {
+			if !strings.Contains(calls[0].Content, content) {
+				t.Fatalf("Expected panel to contain %q, got %q", content, calls[0].Content)
+			}
+		}
+		s.discord.Reset()
+	}
+
+	s.command("embeds", nil, discord.CommandInteractionOption{Type: discord.SubcommandOptionType, Name: "settings"})
+	expectPanel(api.MessageInteractionWithSource, "Embed throttling: on", "Suppressing bot messages: on", "no role embed quotas")
+
+	s.interact(&discord.ButtonInteraction{CustomID: "embeds:toggle:suppress_bot"})
+	expectPanel(api.UpdateMessage, "Suppressing bot messages: off")
+	s.interact(&discord.ButtonInteraction{CustomID: "embeds:toggle:enabled"})
+	expectPanel(api.UpdateMessage, "Embed throttling: off")
+
+	s.interact(&discord.RoleSelectInteraction{CustomID: "embeds:role", Values: []discord.RoleID{50}})
+	modal := s.expectCalls("RespondInteraction", 1)[0].Response
+	if modal.Type != api.ModalResponse || modal.Data.CustomID.Val != "embeds:role_quota:50" {
+		t.Fatalf("Expected the role quota modal, got %+v", modal)
+	}
+	s.discord.Reset()
+
+	submit := func(id discord.ComponentID, values ...string) {
+		var components discord.ContainerComponents
+		for i := 0; i < len(values); i += 2 {
+			components = append(components, &discord.ActionRowComponent{
+				&discord.TextInputComponent{CustomID: discord.ComponentID(values[i]), Value: values[i+1]},
+			})
+		}
+		s.interact(&discord.ModalInteraction{CustomID: id, Components: components})
+	}
+	submit("embeds:role_quota:50", "quota", "abc", "priority", "1")
+	s.expectResponse("❌ Please enter an integer")
+	submit("embeds:role_quota:50", "quota", " 5", "priority", "1")
+	expectPanel(api.UpdateMessage, "<@&50>: 5 (p1)")
+	submit("embeds:attachment_quota", "quota", "2")
+	expectPanel(api.UpdateMessage, "Attachment throttling: 2 per user per day")
+
+	s.interact(&discord.UserSelectInteraction{CustomID: "embeds:reset", Values: []discord.UserID{testUserID}})
+	expectPanel(api.UpdateMessage, "<@30> has been reset")
+}
+
+func TestScenarioAutocomplete(t *testing.T) {
+	s := newScenario(t)
+	s.command("set_link_rewrite", nil, stringOption("from", "x.com"), stringOption("to", "fixupx.com"))
+	s.command("set_link_rewrite", nil, stringOption("from", "twitter.com"), stringOption("to", "fxtwitter.com"))
+	s.discord.Reset()
+
+	focused := func(name, value string) discord.AutocompleteOption {
+		raw, _ := json.Marshal(value)
+		return discord.AutocompleteOption{Type: discord.StringOptionType, Name: name, Value: raw, Focused: true}
+	}
+	s.interact(&discord.AutocompleteInteraction{Name: "remove_link_rewrite", Options: discord.AutocompleteOptions{focused("from", "TWI")}})
+	resp := s.expectCalls("RespondInteraction", 1)[0].Response
+	choices, ok := resp.Data.Choices.(api.AutocompleteStringChoices)
+	if resp.Type != api.AutocompleteResult || !ok || len(choices) != 1 || choices[0].Value != "twitter.com" {
+		t.Fatalf("Expected twitter.com to be suggested, got %+v", resp)
+	}
+	s.discord.Reset()
+
+	if _, err := s.bot.storage.AddChannelSchedule(uint64(testChannelID), storage.Schedule{Weekday: 1, StartMinute: 60, EndMinute: 120}); err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+	s.interact(&discord.AutocompleteInteraction{Name: "schedule_throttling", Options: discord.AutocompleteOptions{
+		{Type: discord.SubcommandOptionType, Name: "remove", Options: discord.AutocompleteOptions{focused("id", "")}},
+	}})
+	resp = s.expectCalls("RespondInteraction", 1)[0].Response
+	if schedules, ok := resp.Data.Choices.(api.AutocompleteIntegerChoices); !ok || len(schedules) != 1 || !strings.HasPrefix(schedules[0].Name, "#1 ") {
+		t.Fatalf("Expected the schedule to be suggested, got %+v", resp.Data.Choices)
+	}
+}
+
+func TestScenarioRoleQuotaRules(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUS
+	intOption := func(name string, value int) discord.CommandInteractionOption {
+		raw, _ := json.Marshal(value)
+		return discord.CommandInteractionOption{Type: discord.IntegerOptionType, Name: name, Value: raw}
+	}
+	roleOption := stringOption("role", "50")
+
+	s.command("set_role_quota", nil, roleOption, intOption("quota", -1), intOption("priority", 0))
+	s.expectResponse("❌ Quota and priority can't be negative")
+	s.command("set_role_quota", nil, roleOption, intOption("quota", 5), intOption("priority", 1))
+	s.expectResponse("has been set to 5")
+
+	s.discord.AddRole(testGuildID, discord.Role{ID: 50, Name: "Member"})
+	s.interact(&discord.AutocompleteInteraction{Name: "remove_role_quota", Options: discord.AutocompleteOptions{
+		{Type: discord.StringOptionType, Name: "role", Value: []byte(`"mem"`), Focused: true},
+	}})
+	resp := s.expectCalls("RespondInteraction", 1)[0].Response
+	if choices, ok := resp.Data.Choices.(api.AutocompleteStringChoices); !ok || len(choices) != 1 || choices[0].Name != "@Member: 5 (p1)" || choices[0].Value != "50" {
+		t.Fatalf("Expected the role quota to be suggested, got %+v", resp.Data.Choices)
+	}
+	s.discord.Reset()
+
+	const category, other, outside discord.ChannelID = 40, 41, 42
+	s.discord.AddChannel(discord.Channel{ID: testChannelID, GuildID: testGuildID, Type: discord.GuildText, ParentID: category})
+	s.discord.AddChannel(discord.Channel{ID: other, GuildID: testGuildID, Type: discord.GuildText, ParentID: category})
+	s.discord.AddChannel(discord.Channel{ID: outside, GuildID: testGuildID, Type: discord.GuildText})
+	s.discord.SetPermissions(other, testUserID, discord.PermissionViewChannel)
+	s.discord.SetPermissions(outside, testUserID, discord.PermissionViewChannel)
+	s.command("apply_role_quotas", nil, stringOption("from", testChannelID.String()), stringOption("category", category.String()))
+	s.expectResponse("❌ You need these permissions in <#40>: Manage Channels")
+	s.command("copy_role_quotas", nil, stringOption("from", testChannelID.String()), stringOption("to", outside.String()))
+	s.expectResponse("❌ You need these permissions in <#42>: Manage Channels")
+	for _, channelID := range []discord.ChannelID{other, outside} {
+		if quotas, err := s.bot.storage.GetAllRoleQuotas(uint64(channelID)); err != nil || len(quotas) != 0 {
+			t.Fatalf("Expected no quota to be copied to %d, got %v (%v)", channelID, quotas, err)
+		}
+	}
+
+	s.discord.SetPermissions(other, testUserID, discord.PermissionManageChannels)
+	s.discord.SetPermissions(outside, testUserID, discord.PermissionManageChannels)
+	s.command("apply_role_quotas", nil, stringOption("from", testChannelID.String()), stringOption("category", category.String()))
+	s.expectResponse("Applied 1 role embed quotas of <#20> to 1 channels in <#40>")
+	s.command("copy_role_quotas", nil, stringOption("from", testChannelID.String()), stringOption("to", outside.String()))
+	s.expectResponse("Copied 1 role embed quotas of <#20> to <#42>")
+	for _, channelID := range []discord.ChannelID{other, outside} {
+		if quotas, err := s.bot.storage.GetAllRoleQuotas(uint64(channelID)); err != nil || len(quotas) != 1 {
+			t.Fatalf("Expected the quota to be copied to %d, got %v (%v)", channelID, quotas, err)
+		}
+	}
+
+	s.command("remove_role_quota", nil, roleOption)
+	s.expectResponse("The embed quota of <@&50> has been removed")
+	s.command("remove_role_quota", nil, roleOption)
+	s.expectResponse("❌ <@&50> has no embed quota in this channel")
+	s.command("copy_role_quotas", nil, stringOption("from", testChannelID.String()), stringOption("to", outside.String()))
+	s.expectResponse("❌ <#20> has no role embed quotas")
+}
+
+func TestScenarioPermissions(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUS
+	const adminRole discord.RoleID = 60
+	subcommand := func(name string, options ...discord.CommandInteractionOption) discord.CommandInteractionOption {
+		return discord.CommandInteractionOption{Type: discord.SubcommandOptionType, Name: name, Options: options}
+	}
+	roleOption := stringOption("role", adminRole.String())
+
+	s.discord.SetPermissions(testChannelID, testUserID, discord.PermissionViewChannel)
+	s.command("set_attachment_quota", nil, stringOption("quota", "1"))
+	s.expectResponse("❌ You need these permissions to use this command: Manage Channels")
+
+	if err := s.bot.storage.AddBotAdminRole(uint64(testGuildID), uint64(adminRole)); err != nil {
+		t.Fatalf("Failed to add bot-admin role: %v", err)
+	}
+	s.roles = []discord.RoleID{adminRole}
+	s.command("bot_admin_roles", nil, subcommand("list"))
+	s.expectResponse("❌ You need these permissions to use this command: Manage Server")
+	s.command("toggle_channel", nil)
+	s.expectResponse("Embed throttling has been **disabled**")
+
+	s.discord.SetPermissions(testChannelID, 1, discord.PermissionViewChannel|discord.PermissionAddReactions)
+	s.command("toggle_channel", nil)
+	s.expectResponse("❌ I need these permissions in this channel: Manage Messages")
+
+	s.discord.SetPermissions(testChannelID, testUserID, discord.PermissionManageGuild)
+	s.command("bot_admin_roles", nil, subcommand("remove", roleOption))
+	s.expectResponse("Bot-admin role <@&60> removed")
+	s.command("bot_admin_roles", nil, subcommand("remove", roleOption))
+	s.expectResponse("❌ <@&60> is not a bot-admin role")
+	s.command("bot_admin_roles", nil, subcommand("list"))
+	s.expectResponse("This server has no bot-admin roles")
+}
+
+func TestScenarioRateLimit(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUS
+	s.bot.config.RateLimitBurst = 2
+	s.bot.config.RateLimitPeriod = time.Minute
+
+	for i := 0; i < 2; i++ {
+		s.command("my_quota", nil)
+		s.expectResponse("Embed quota in this channel")
+	}
+	s.command("my_quota", nil)
+	s.expectResponse("❌ You're doing that too often, try again in 30 seconds")
+	s.command("toggle_channel", nil)
+	s.expectResponse("Embed throttling has been **disabled**")
+
+	// One use is refilled every 30 seconds
+	s.clock.Advance(30 * time.Second)
+	s.command("my_quota", nil)
+	s.expectResponse("Embed quota in this channel")
+	s.command("my_quota", nil)
+	s.expectResponse("❌ You're doing that too often")
+	if limited := rateLimitLimited.Get("my_quota"); limited == nil || limited.String() == "0" {
+		t.Fatalf("Expected the limited interactions to be counted, got %v", limited)
+	}
+}
+
+func TestScenarioDeferredResponse(t *testing.T) {
+	s := newScenario(t)
+	s.locale = discord.EnglishUS
+	s.bot.deferAfter = 10 * time.Millisecond
+	slow := func(i *gateway.InteractionCreateEvent) error {
+		time.Sleep(100 * time.Millisecond)
+		return s.bot.RespondError(i, "error.discord")
+	}
+	s.bot.registry = NewCommandRegistry(&Command{
+		Data:       api.CreateCommandData{Name: "slow"},
+		Handler:    slow,
+		Components: map[string]CommandHandler{"button": slow},
+	})
+
+	s.command("slow", nil)
+	resp := s.expectCalls("RespondInteraction", 1)[0].Response
+	if resp.Type != api.DeferredMessageInteractionWithSource || resp.Data.Flags&discord.EphemeralMessage == 0 {
+		t.Fatalf("Expected an ephemeral deferred response, got %+v", resp)
+	}
+	if calls := s.expectCalls("EditInteractionResponse", 1); !strings.HasPrefix(calls[0].Content, "❌") {
+		t.Fatalf("Expected the deferred response to be edited with the error, got %q", calls[0].Content)
+	}
+	s.discord.Reset()
+
+	// The error of a component is sent besides its message
+	s.interact(&discord.ButtonInteraction{CustomID: "slow:button"})
+	if resp := s.expectCalls("RespondInteraction", 1)[0].Response; resp.Type != api.DeferredMessageUpdate {
+		t.Fatalf("Expected a deferred update, got %+v", resp)
+	}
+	s.expectCalls("EditInteractionResponse", 0)
+	if calls := s.expectCalls("FollowUpInteraction", 1); *calls[0].Flags&discord.EphemeralMessage == 0 {
+		t.Fatalf("Expected an ephemeral follow up, got %+v", calls[0])
+	}
+	s.discord.Reset()
+
+	s.bot.deferAfter = time.Minute
+	s.command("slow", nil)
+	s.expectResponse("❌")
+	s.expectCalls("EditInteractionResponse", 0)
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// guildLocation returns the timezone of the guild, falling back to storage.DefaultTimezone.
+func (b *Bot) guildLocation(guildID discord.GuildID) *time.Location {
+	name := storage.DefaultTimezone
+	if guildID.IsValid() {
+		var err error
+		name, err = b.storage.GetGuildTimezone(uint64(guildID))
+		if err != nil {
+			log.Printf("Error getting timezone of guild %d: %v", guildID, err)
+			name = storage.DefaultTimezone
+		}
+	}
+
+	loc, err := loadLocation(name)
+	if err != nil {
+		log.Printf("Error loading timezone %s: %v", name, err)
+		loc, _ = loadLocation(storage.DefaultTimezone)
+	}
+	return loc
+}
+
+// isChannelEnabled reports whether throttling is enabled and, if the channel has schedules, currently active.
+func (b *Bot) isChannelEnabled(channelID discord.ChannelID, guildID discord.GuildID) (bool, error) {
+	enabled, err := b.storage.IsChannelEnabled(uint64(channelID))
+	if err != nil {
+		return false, err
+	}
+	if !enabled && !b.config.DefaultEnabled {
+		return false, nil
+	}
+
+	return b.storage.IsChannelScheduledAt(uint64(channelID), b.clock.Now().In(b.guildLocation(guildID)))
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
+
+func formatClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// weekdayKey is the message key of the weekday, negative weekdays are every day.
+func weekdayKey(weekday int) string {
+	if weekday < 0 || weekday > int(time.Saturday) {
+		return "weekday.every"
+	}
+	return "weekday." + strconv.Itoa(weekday)
+}
+
+func formatSchedule(lang discord.Language, schedule storage.Schedule) string {
+	day := tr(lang, weekdayKey(schedule.Weekday))
+	if schedule.StartMinute == schedule.EndMinute {
+		return tr(lang, "schedule.all_day", day)
+	}
+	return fmt.Sprintf("%s %s ~ %s", day, formatClock(schedule.StartMinute), formatClock(schedule.EndMinute))
+}
+
+func weekdayChoices() []discord.IntegerChoice {
+	choices := make([]discord.IntegerChoice, 0, 8)
+	for weekday := -1; weekday <= int(time.Saturday); weekday++ {
+		name, names := localizeDescription(weekdayKey(weekday))
+		choices = append(choices, discord.IntegerChoice{Name: name, NameLocalizations: names, Value: weekday})
+	}
+	return choices
+}
+
+func (b *Bot) handleAddSchedule(i *gateway.InteractionCreateEvent) error {
+	options := subcommandOptions(i.Data.(*discord.CommandInteraction))
+	weekday, err := options.Find("weekday").IntValue()
+	if err != nil {
+		return err
+	}
+	start, err := parseClock(options.Find("start").String())
+	if err != nil {
+		return b.RespondError(i, "error.invalid_start")
+	}
+	end, err := parseClock(options.Find("end").String())
+	if err != nil {
+		return b.RespondError(i, "error.invalid_end")
+	}
+
+	schedule := storage.Schedule{
+		Weekday:     int(weekday),
+		StartMinute: start,
+		EndMinute:   end,
+	}
+	schedule.ID, err = b.storage.AddChannelSchedule(uint64(i.ChannelID), schedule)
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	return b.respondSchedule(i, tr(lang, "schedule.added", schedule.ID, formatSchedule(lang, schedule)))
+}
+
+func (b *Bot) handleRemoveSchedule(i *gateway.InteractionCreateEvent) error {
+	options := subcommandOptions(i.Data.(*discord.CommandInteraction))
+	id, err := options.Find("id").IntValue()
+	if err != nil {
+		return err
+	}
+	removed, err := b.storage.RemoveChannelSchedule(uint64(i.ChannelID), id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return b.RespondError(i, "error.schedule_not_found", id)
+	}
+
+	return b.respondSchedule(i, tr(interactionLanguage(i), "schedule.removed", id))
+}
+
+func (b *Bot) handleListSchedules(i *gateway.InteractionCreateEvent) error {
+	schedules, err := b.storage.GetChannelSchedules(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	loc := b.guildLocation(i.GuildID)
+	sb := strings.Builder{}
+	if len(schedules) == 0 {
+		sb.WriteString(tr(lang, "schedule.none") + "\n")
+	} else {
+		sb.WriteString(tr(lang, "schedule.list", loc) + "\n")
+		for _, schedule := range schedules {
+			sb.WriteString(fmt.Sprintf("-# - #%d %s\n", schedule.ID, formatSchedule(lang, schedule)))
+		}
+	}
+	active, err := b.storage.IsChannelScheduledAt(uint64(i.ChannelID), b.clock.Now().In(loc))
+	if err != nil {
+		return err
+	}
+	if active {
+		sb.WriteString(tr(lang, "schedule.active"))
+	} else {
+		sb.WriteString(tr(lang, "schedule.inactive"))
+	}
+
+	return b.respondSchedule(i, sb.String())
+}
+
+func (b *Bot) respondSchedule(i *gateway.InteractionCreateEvent, msg string) error {
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(msg),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleSetTimezone(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.CommandInteraction)
+	name := strings.TrimSpace(data.Options.Find("timezone").String())
+	loc, err := loadLocation(name)
+	if err != nil || name == "" || strings.EqualFold(name, "local") {
+		return b.RespondError(i, "error.invalid_timezone", name)
+	}
+
+	err = b.storage.SetGuildTimezone(uint64(i.GuildID), loc.String())
+	if err != nil {
+		return err
+	}
+
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(tr(interactionLanguage(i), "timezone.set", loc, b.clock.Now().In(loc).Format("15:04"))),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
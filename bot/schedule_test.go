@@ -0,0 +1,27 @@
+package bot
+
+import "testing"
+
+func TestParseClock(t *testing.T) {
+	for input, expected := range map[string]int{
+		"00:00": 0,
+		"9:30":  9*60 + 30,
+		"23:59": 23*60 + 59,
+		"24:00": 0,
+	} {
+		minute, err := parseClock(input)
+		if err != nil {
+			t.Errorf("parseClock(%q) failed: %v", input, err)
+			continue
+		}
+		if minute != expected {
+			t.Errorf("parseClock(%q): expected %d, got %d", input, expected, minute)
+		}
+	}
+
+	for _, input := range []string{"", "12", "25:00", "12:60", "24:01", "ab:cd", "-1:00"} {
+		if _, err := parseClock(input); err == nil {
+			t.Errorf("Expected parseClock(%q) to fail", input)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/No3371/dc_embed_throttler/clock"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/state"
+)
+
+// CommandScope is where commands are registered, globally if GuildID is zero, and which of them.
+type CommandScope struct {
+	GuildID      discord.GuildID
+	Stable       bool
+	Experimental bool
+}
+
+func (s CommandScope) String() string {
+	if s.GuildID == 0 {
+		return "global"
+	}
+	return fmt.Sprintf("guild %d", s.GuildID)
+}
+
+func (s CommandScope) includes(cmd *Command) bool {
+	if cmd.Experimental {
+		return s.Experimental
+	}
+	return s.Stable
+}
+
+// GuildScope is what is registered in the guild, or globally if guildID is zero. With dev guilds
+// configured, they get every command and nothing is registered elsewhere. Otherwise stable commands
+// are registered globally and experimental ones in experimental guilds.
+func GuildScope(cfg *config.Config, guildID discord.GuildID) CommandScope {
+	scope := CommandScope{GuildID: guildID}
+	switch {
+	case len(cfg.DevGuilds) > 0:
+		if slices.Contains(cfg.DevGuilds, uint64(guildID)) {
+			scope.Stable, scope.Experimental = true, true
+		}
+	case guildID == 0:
+		scope.Stable = true
+	case slices.Contains(cfg.ExperimentalGuilds, uint64(guildID)):
+		scope.Experimental = true
+	}
+	return scope
+}
+
+// CommandScopes are the scopes synced on start. With dev guilds configured, commands are only
+// registered in them and the global commands are left untouched.
+func CommandScopes(cfg *config.Config) []CommandScope {
+	var scopes []CommandScope
+	if len(cfg.DevGuilds) > 0 {
+		for _, id := range cfg.DevGuilds {
+			scopes = append(scopes, GuildScope(cfg, discord.GuildID(id)))
+		}
+		return scopes
+	}
+	scopes = append(scopes, GuildScope(cfg, 0))
+	for _, id := range cfg.ExperimentalGuilds {
+		scopes = append(scopes, GuildScope(cfg, discord.GuildID(id)))
+	}
+	return scopes
+}
+
+// scopedClient calls the global or guild command endpoints depending on the scope.
+type scopedClient struct {
+	client  Discord
+	appID   discord.AppID
+	guildID discord.GuildID
+}
+
+func (c scopedClient) commands() ([]discord.Command, error) {
+	if c.guildID == 0 {
+		return c.client.Commands(c.appID)
+	}
+	return c.client.GuildCommands(c.appID, c.guildID)
+}
+
+func (c scopedClient) create(data api.CreateCommandData) error {
+	var err error
+	if c.guildID == 0 {
+		_, err = c.client.CreateCommand(c.appID, data)
+	} else {
+		_, err = c.client.CreateGuildCommand(c.appID, c.guildID, data)
+	}
+	return err
+}
+
+func (c scopedClient) edit(id discord.CommandID, data api.CreateCommandData) error {
+	var err error
+	if c.guildID == 0 {
+		_, err = c.client.EditCommand(c.appID, id, data)
+	} else {
+		_, err = c.client.EditGuildCommand(c.appID, c.guildID, id, data)
+	}
+	return err
+}
+
+func (c scopedClient) delete(id discord.CommandID) error {
+	if c.guildID == 0 {
+		return c.client.DeleteCommand(c.appID, id)
+	}
+	return c.client.DeleteGuildCommand(c.appID, c.guildID, id)
+}
+
+func (c scopedClient) deleteAll() error {
+	var err error
+	if c.guildID == 0 {
+		_, err = c.client.BulkOverwriteCommands(c.appID, nil)
+	} else {
+		_, err = c.client.BulkOverwriteGuildCommands(c.appID, c.guildID, nil)
+	}
+	return err
+}
+
+// ManageCommands runs the commands subcommand against Discord without opening a gateway connection.
+// action is list, diff or delete, the global commands are managed if guildID is zero.
+func ManageCommands(w io.Writer, cfg *config.Config, action string, guildID discord.GuildID, names []string) error {
+	s := state.New("Bot " + cfg.Token)
+	app, err := s.CurrentApplication()
+	if err != nil {
+		return fmt.Errorf("fetching application: %w", err)
+	}
+	// No interaction is handled, the bot is only built for its commands and doesn't need the storage
+	client := stateClient{s}
+	b, err := newBot(cfg, nil, clock.System, client)
+	if err != nil {
+		return err
+	}
+	return manageCommands(w, client, app.ID, b.registry, action, GuildScope(cfg, guildID), names)
+}
+
+func manageCommands(w io.Writer, client Discord, appID discord.AppID, registry *CommandRegistry, action string, scope CommandScope, names []string) error {
+	sc := scopedClient{client, appID, scope.GuildID}
+	live, err := sc.commands()
+	if err != nil {
+		return fmt.Errorf("fetching %s commands: %w", scope, err)
+	}
+
+	switch action {
+	case "list":
+		for _, cmd := range live {
+			fmt.Fprintf(w, "%d\t%s\n", cmd.ID, commandLabel(cmd.Type, cmd.Name))
+		}
+		fmt.Fprintf(w, "%d %s commands\n", len(live), scope)
+	case "diff":
+		diff := DiffCommands(registry.ScopeData(scope), live)
+		for _, data := range diff.Create {
+			fmt.Fprintf(w, "+ %s\n", commandLabel(data.Type, data.Name))
+		}
+		for _, edit := range diff.Edit {
+			fmt.Fprintf(w, "~ %s\n", commandLabel(edit.Data.Type, edit.Data.Name))
+		}
+		for _, cmd := range diff.Delete {
+			fmt.Fprintf(w, "- %s\n", commandLabel(cmd.Type, cmd.Name))
+		}
+		fmt.Fprintf(w, "%s: %s\n", scope, diff)
+	case "delete":
+		// Without names, every command of the scope is deleted at once
+		if len(names) == 0 {
+			if err := sc.deleteAll(); err != nil {
+				return fmt.Errorf("deleting %s commands: %w", scope, err)
+			}
+			fmt.Fprintf(w, "Deleted %d %s commands\n", len(live), scope)
+			return nil
+		}
+		for _, name := range names {
+			i := slices.IndexFunc(live, func(cmd discord.Command) bool { return cmd.Name == name })
+			if i < 0 {
+				return fmt.Errorf("no %s command named %s", scope, name)
+			}
+			if err := sc.delete(live[i].ID); err != nil {
+				return fmt.Errorf("deleting command %s: %w", name, err)
+			}
+			fmt.Fprintf(w, "Deleted %s command %s\n", scope, name)
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected list, diff or delete", action)
+	}
+	return nil
+}
+
+// commandLabel is how a command is shown in the context menus or chat input.
+func commandLabel(t discord.CommandType, name string) string {
+	switch t {
+	case discord.MessageCommand:
+		return name + " (message)"
+	case discord.UserCommand:
+		return name + " (user)"
+	}
+	return "/" + name
+}
@@ -0,0 +1,321 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const settingsCommand = "embeds"
+
+// Toggles of the settings panel, the arguments of its toggle buttons
+const (
+	settingEnabled     = "enabled"
+	settingSuppressBot = "suppress_bot"
+	settingLinkSummary = "link_summary"
+)
+
+// settingsPanel shows the settings of the channel with the components to change them, status is shown on top if not empty.
+func (b *Bot) settingsPanel(lang discord.Language, channelID discord.ChannelID, status string) (*api.InteractionResponseData, error) {
+	enabled, err := b.storage.IsChannelEnabled(uint64(channelID))
+	if err != nil {
+		return nil, err
+	}
+	suppressBot, err := b.storage.IsChannelSuppressBot(uint64(channelID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	linkSummary, err := b.storage.IsChannelLinkSummary(uint64(channelID))
+	if err != nil {
+		return nil, err
+	}
+	attachmentQuota, err := b.storage.GetAttachmentQuota(uint64(channelID))
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := b.storage.GetAllRoleQuotas(uint64(channelID))
+	if err != nil {
+		return nil, err
+	}
+
+	onOff := func(on bool) string {
+		if on {
+			return tr(lang, "settings.on")
+		}
+		return tr(lang, "settings.off")
+	}
+	sb := strings.Builder{}
+	if status != "" {
+		sb.WriteString(status + "\n")
+	}
+	sb.WriteString(tr(lang, "settings.title") + "\n")
+	sb.WriteString(tr(lang, "settings.enabled", onOff(enabled)) + "\n")
+	sb.WriteString(tr(lang, "settings.suppress_bot", onOff(suppressBot)) + "\n")
+	sb.WriteString(tr(lang, "settings.link_summary", onOff(linkSummary)) + "\n")
+	if attachmentQuota < 0 {
+		sb.WriteString(tr(lang, "settings.attachment_disabled") + "\n")
+	} else {
+		sb.WriteString(tr(lang, "settings.attachment_quota", attachmentQuota) + "\n")
+	}
+	sb.WriteString(tr(lang, "settings.default_quota", b.config.DefaultQuota) + "\n")
+	if len(quotas) == 0 {
+		sb.WriteString(tr(lang, "settings.no_role_quotas") + "\n")
+	} else {
+		sb.WriteString(tr(lang, "role_quota.list") + "\n")
+		for _, quota := range quotas {
+			sb.WriteString(tr(lang, "role_quota.item", quota.RoleID, quota.Quota, quota.Priority) + "\n")
+		}
+	}
+
+	toggle := func(setting string, on bool) *discord.ButtonComponent {
+		style := discord.SecondaryButtonStyle()
+		if on {
+			style = discord.SuccessButtonStyle()
+		}
+		return &discord.ButtonComponent{
+			Style:    style,
+			CustomID: customID(settingsCommand, "toggle", setting),
+			Label:    tr(lang, "settings.button."+setting),
+		}
+	}
+	components := discord.ContainerComponents{
+		&discord.ActionRowComponent{
+			toggle(settingEnabled, enabled),
+			toggle(settingSuppressBot, suppressBot),
+			toggle(settingLinkSummary, linkSummary),
+			&discord.ButtonComponent{
+				Style:    discord.PrimaryButtonStyle(),
+				CustomID: customID(settingsCommand, "attachment"),
+				Label:    tr(lang, "settings.button.attachment"),
+			},
+		},
+		&discord.ActionRowComponent{
+			&discord.RoleSelectComponent{
+				CustomID:    customID(settingsCommand, "role"),
+				Placeholder: tr(lang, "settings.role_placeholder"),
+			},
+		},
+		&discord.ActionRowComponent{
+			&discord.UserSelectComponent{
+				CustomID:    customID(settingsCommand, "reset"),
+				Placeholder: tr(lang, "settings.reset_placeholder"),
+				ValueLimits: [2]int{1, 25},
+			},
+		},
+	}
+	return &api.InteractionResponseData{
+		Content:    option.NewNullableString(sb.String()),
+		Components: &components,
+		Flags:      discord.EphemeralMessage,
+	}, nil
+}
+
+func (b *Bot) handleEmbedsSettings(i *gateway.InteractionCreateEvent) error {
+	data, err := b.settingsPanel(interactionLanguage(i), i.ChannelID, "")
+	if err != nil {
+		return err
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: data,
+	})
+}
+
+// updateSettingsPanel refreshes the panel the component belongs to, with status on top.
+func (b *Bot) updateSettingsPanel(i *gateway.InteractionCreateEvent, status string) error {
+	data, err := b.settingsPanel(interactionLanguage(i), i.ChannelID, status)
+	if err != nil {
+		return err
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.UpdateMessage,
+		Data: data,
+	})
+}
+
+func (b *Bot) handleSettingsToggle(i *gateway.InteractionCreateEvent) error {
+	channelID := uint64(i.ChannelID)
+	var key string
+	switch setting := customIDArg(i); setting {
+	case settingEnabled:
+		enabled, err := b.storage.IsChannelEnabled(channelID)
+		if err != nil {
+			return b.RespondError(i, "error.check_channel")
+		}
+		if err := b.storage.SetChannelEnabled(channelID, !enabled); err != nil {
+			return b.RespondError(i, "error.toggle_channel")
+		}
+		key = toggleKey("toggle_channel", !enabled)
+	case settingSuppressBot:
+		suppressBot, err := b.storage.IsChannelSuppressBot(channelID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := b.storage.SetChannelSuppressBot(channelID, !suppressBot); err != nil {
+			return err
+		}
+		key = toggleKey("toggle_suppress_bot", !suppressBot)
+	case settingLinkSummary:
+		linkSummary, err := b.storage.IsChannelLinkSummary(channelID)
+		if err != nil {
+			return err
+		}
+		if err := b.storage.SetChannelLinkSummary(channelID, !linkSummary); err != nil {
+			return err
+		}
+		key = toggleKey("toggle_link_summary", !linkSummary)
+	default:
+		return b.RespondError(i, "error.unknown_choice")
+	}
+	return b.updateSettingsPanel(i, tr(interactionLanguage(i), key))
+}
+
+func toggleKey(prefix string, enabled bool) string {
+	if enabled {
+		return prefix + ".enabled"
+	}
+	return prefix + ".disabled"
+}
+
+// numberInput is a text input of a modal for an integer.
+func numberInput(id, label string, value int) *discord.ActionRowComponent {
+	return &discord.ActionRowComponent{
+		&discord.TextInputComponent{
+			CustomID:     discord.ComponentID(id),
+			Style:        discord.TextInputShortStyle,
+			Label:        label,
+			LengthLimits: [2]int{1, 6},
+			Required:     true,
+			Value:        strconv.Itoa(value),
+		},
+	}
+}
+
+// modalNumber parses the integer entered in the text input of the submitted modal.
+func modalNumber(i *gateway.InteractionCreateEvent, id string) (int, error) {
+	data := i.Data.(*discord.ModalInteraction)
+	input, ok := data.Components.Find(discord.ComponentID(id)).(*discord.TextInputComponent)
+	if !ok {
+		return 0, errors.New("missing input " + id)
+	}
+	return strconv.Atoi(strings.TrimSpace(input.Value))
+}
+
+func (b *Bot) respondModal(i *gateway.InteractionCreateEvent, id discord.ComponentID, title string, inputs ...*discord.ActionRowComponent) error {
+	components := make(discord.ContainerComponents, len(inputs))
+	for j, input := range inputs {
+		components[j] = input
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.ModalResponse,
+		Data: &api.InteractionResponseData{
+			CustomID:   option.NewNullableString(string(id)),
+			Title:      option.NewNullableString(title),
+			Components: &components,
+		},
+	})
+}
+
+func (b *Bot) handleSettingsRole(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.RoleSelectInteraction)
+	if len(data.Values) == 0 {
+		return b.updateSettingsPanel(i, "")
+	}
+	roleID := data.Values[0]
+
+	quotas, err := b.storage.GetAllRoleQuotas(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+	quota, priority := b.config.DefaultQuota, 0
+	for _, q := range quotas {
+		if q.RoleID == uint64(roleID) {
+			quota, priority = q.Quota, q.Priority
+		}
+	}
+
+	lang := interactionLanguage(i)
+	return b.respondModal(i, customID(settingsCommand, "role_quota", roleID.String()), tr(lang, "settings.role_modal"),
+		numberInput("quota", tr(lang, "cmd.set_role_quota.quota"), quota),
+		numberInput("priority", tr(lang, "cmd.set_role_quota.priority"), priority),
+	)
+}
+
+func (b *Bot) handleSettingsRoleQuota(i *gateway.InteractionCreateEvent) error {
+	roleID, err := discord.ParseSnowflake(customIDArg(i))
+	if err != nil {
+		return err
+	}
+	quota, err := modalNumber(i, "quota")
+	if err != nil {
+		return b.RespondError(i, "error.invalid_number")
+	}
+	priority, err := modalNumber(i, "priority")
+	if err != nil {
+		return b.RespondError(i, "error.invalid_number")
+	}
+	if quota < 0 || priority < 0 {
+		return b.RespondError(i, "error.negative_role_quota")
+	}
+
+	err = b.storage.ConfigureRoleQuota(uint64(i.ChannelID), uint64(roleID), quota, priority)
+	if err != nil {
+		return err
+	}
+	return b.updateSettingsPanel(i, tr(interactionLanguage(i), "role_quota.set", roleID, quota))
+}
+
+func (b *Bot) handleSettingsReset(i *gateway.InteractionCreateEvent) error {
+	data := i.Data.(*discord.UserSelectInteraction)
+	lang := interactionLanguage(i)
+	var status []string
+	for _, userID := range data.Values {
+		if err := b.storage.ResetQuotaUsage(uint64(userID), uint64(i.ChannelID)); err != nil {
+			return err
+		}
+		if err := b.storage.ResetAttachmentUsage(uint64(userID), uint64(i.ChannelID)); err != nil {
+			return err
+		}
+		status = append(status, tr(lang, "quota.reset", userID))
+	}
+	return b.updateSettingsPanel(i, strings.Join(status, "\n"))
+}
+
+func (b *Bot) handleSettingsAttachment(i *gateway.InteractionCreateEvent) error {
+	quota, err := b.storage.GetAttachmentQuota(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+	lang := interactionLanguage(i)
+	return b.respondModal(i, customID(settingsCommand, "attachment_quota"), tr(lang, "settings.attachment_modal"),
+		numberInput("quota", tr(lang, "cmd.set_attachment_quota.quota"), quota),
+	)
+}
+
+func (b *Bot) handleSettingsAttachmentQuota(i *gateway.InteractionCreateEvent) error {
+	quota, err := modalNumber(i, "quota")
+	if err != nil {
+		return b.RespondError(i, "error.invalid_number")
+	}
+	if quota < 0 {
+		quota = -1
+	}
+
+	err = b.storage.SetAttachmentQuota(uint64(i.ChannelID), quota)
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	status := tr(lang, "attachment_quota.set", quota)
+	if quota < 0 {
+		status = tr(lang, "attachment_quota.disabled")
+	}
+	return b.updateSettingsPanel(i, status)
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"log"
+	"strings"
+
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// shadowAllow is the shadow decision of messages that would not have been suppressed.
+const shadowAllow = "allow"
+
+// shadowAttachment is the shadow decision of messages that would have been deleted for the attachment quota.
+const shadowAttachment = "attachment"
+
+// shadowDecisions are the decisions listed in /shadow_report, named by "shadow.decision.<decision>".
+var shadowDecisions = []string{shadowAllow, reasonQuota.String(), reasonBurst.String(), reasonDuplicate.String(), shadowAttachment}
+
+// isChannelShadow reports whether the channel is in shadow mode and, if the channel has schedules, currently active.
+func (b *Bot) isChannelShadow(channelID discord.ChannelID, guildID discord.GuildID) (bool, error) {
+	shadow, err := b.storage.IsChannelShadow(uint64(channelID))
+	if err != nil || !shadow {
+		return false, err
+	}
+
+	return b.storage.IsChannelScheduledAt(uint64(channelID), b.clock.Now().In(b.guildLocation(guildID)))
+}
+
+func (b *Bot) recordShadow(m *gateway.MessageCreateEvent, userID uint64, decision string, cost int) {
+	log.Printf("(Shadow) Message %d in #%d by %d: %s (cost %d)", m.ID, m.ChannelID, userID, decision, cost)
+	err := b.storage.RecordShadowDecision(storage.ShadowDecision{
+		ChannelID: uint64(m.ChannelID),
+		MessageID: uint64(m.ID),
+		UserID:    userID,
+		Decision:  decision,
+		Cost:      cost,
+		DecidedAt: b.clock.Now(),
+	})
+	if err != nil {
+		log.Printf("Error recording shadow decision: %v", err)
+	}
+}
+
+func (b *Bot) handleToggleShadow(i *gateway.InteractionCreateEvent) error {
+	shadow, err := b.storage.IsChannelShadow(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+
+	if !shadow {
+		// Every shadow run is reported on its own
+		err = b.storage.ResetShadowDecisions(uint64(i.ChannelID))
+		if err != nil {
+			return err
+		}
+	}
+	err = b.storage.SetChannelShadow(uint64(i.ChannelID), !shadow)
+	if err != nil {
+		return err
+	}
+
+	key := "shadow.disabled"
+	if !shadow {
+		key = "shadow.enabled"
+	}
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(tr(interactionLanguage(i), key)),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
+
+func (b *Bot) handleShadowReport(i *gateway.InteractionCreateEvent) error {
+	shadow, err := b.storage.IsChannelShadow(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+	counts, err := b.storage.GetShadowCounts(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+	users, err := b.storage.GetShadowSuppressedUsers(uint64(i.ChannelID), 10)
+	if err != nil {
+		return err
+	}
+
+	lang := interactionLanguage(i)
+	sb := strings.Builder{}
+	if shadow {
+		sb.WriteString(tr(lang, "shadow.report_active") + "\n")
+	} else {
+		sb.WriteString(tr(lang, "shadow.report_inactive") + "\n")
+	}
+	total := 0
+	for _, decision := range shadowDecisions {
+		total += counts[decision]
+		sb.WriteString(tr(lang, "shadow.count", tr(lang, "shadow.decision."+decision), counts[decision]) + "\n")
+	}
+	if total > 0 {
+		sb.WriteString(tr(lang, "shadow.total", total, total-counts[shadowAllow]) + "\n")
+	}
+	if len(users) > 0 {
+		sb.WriteString(tr(lang, "shadow.users") + "\n")
+		for _, user := range users {
+			sb.WriteString(tr(lang, "shadow.user", user.UserID, user.Suppressed) + "\n")
+		}
+	}
+
+	respd := api.InteractionResponseData{
+		Content:         option.NewNullableString(sb.String()),
+		Flags:           discord.EphemeralMessage,
+		AllowedMentions: &api.AllowedMentions{Parse: []api.AllowedMentionType{}},
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
@@ -0,0 +1,239 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/session"
+	"github.com/diamondburned/arikawa/v3/session/shard"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/state/store/defaultstore"
+	"github.com/diamondburned/arikawa/v3/utils/handler"
+)
+
+// statusBot is the last started bot, whose shards are reported by the shards expvar.
+var statusBot atomic.Pointer[Bot]
+
+func init() {
+	expvar.Publish("shards", expvar.Func(func() any {
+		b := statusBot.Load()
+		if b == nil {
+			return nil
+		}
+		return b.ShardStatuses()
+	}))
+}
+
+// newShardManager creates the shards of the bot, as many as Discord recommends unless the count is configured.
+// The shards are created by arikawa but not opened, see selectShards.
+func newShardManager(cfg *config.Config) (*shard.Manager, []shard.ShardState, error) {
+	var shards []shard.ShardState
+	newShard := func(m *shard.Manager, id *gateway.Identifier) (shard.Shard, error) {
+		s := state.NewFromSession(session.NewCustom(*id, api.NewClient(id.Token), handler.New()), defaultstore.New())
+		shards = append(shards, shard.ShardState{Shard: s, ID: *id})
+		return s, nil
+	}
+
+	token := "Bot " + cfg.Token
+	var m *shard.Manager
+	var err error
+	if cfg.ShardCount > 0 {
+		data := gateway.DefaultIdentifyCommand(token)
+		data.SetShard(0, cfg.ShardCount)
+		m, err = shard.NewIdentifiedManager(data, newShard)
+	} else {
+		m, err = shard.NewManager(token, newShard)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, shards, nil
+}
+
+// selectShards picks the shards run by this process, all of them if ids is empty.
+func selectShards(shards []shard.ShardState, ids []int) ([]shard.ShardState, error) {
+	if len(ids) == 0 {
+		return shards, nil
+	}
+	selected := make([]shard.ShardState, 0, len(ids))
+	for _, id := range ids {
+		i := slices.IndexFunc(shards, func(s shard.ShardState) bool { return s.ShardID() == id })
+		if i < 0 {
+			return nil, fmt.Errorf("shard %d out of %d shards", id, len(shards))
+		}
+		selected = append(selected, shards[i])
+	}
+	return selected, nil
+}
+
+// shardedClient is the Discord client of the shards. The API is called through the first shard, the
+// state of the shard of a guild is used for what is cached about it.
+type shardedClient struct {
+	stateClient
+	manager *shard.Manager
+}
+
+var _ Discord = shardedClient{}
+
+// guildState is the client of the shard receiving the events of the guild. The state falls back to the
+// API if the shard isn't run by this process.
+func (c shardedClient) guildState(guildID discord.GuildID) stateClient {
+	sh, _ := c.manager.FromGuildID(guildID)
+	if s, ok := sh.(*state.State); ok {
+		return stateClient{s}
+	}
+	return c.stateClient
+}
+
+func (c shardedClient) Channels(guildID discord.GuildID) ([]discord.Channel, error) {
+	return c.guildState(guildID).Channels(guildID)
+}
+
+func (c shardedClient) Roles(guildID discord.GuildID) ([]discord.Role, error) {
+	return c.guildState(guildID).Roles(guildID)
+}
+
+// Permissions are computed by the shard that has the channel cached, if any.
+func (c shardedClient) Permissions(channelID discord.ChannelID, userID discord.UserID) (discord.Permissions, error) {
+	client := c.stateClient
+	c.manager.ForEach(func(sh shard.Shard) {
+		if s, ok := sh.(*state.State); ok {
+			if _, err := s.Cabinet.Channel(channelID); err == nil {
+				client = stateClient{s}
+			}
+		}
+	})
+	return client.Permissions(channelID, userID)
+}
+
+// ShardStatus is the health of a shard.
+type ShardStatus struct {
+	ID    int  `json:"id"`
+	Ready bool `json:"ready"`
+	// Alive is whether the gateway connection is up.
+	Alive   bool      `json:"alive"`
+	Guilds  int       `json:"guilds"`
+	ReadyAt time.Time `json:"ready_at,omitzero"`
+	Resumes int       `json:"resumes"`
+}
+
+// shardHealth tracks the shards run by this process from their events.
+type shardHealth struct {
+	mu     sync.Mutex
+	shards map[int]*ShardStatus
+}
+
+func newShardHealth(ids []int) *shardHealth {
+	h := &shardHealth{shards: make(map[int]*ShardStatus, len(ids))}
+	for _, id := range ids {
+		h.shards[id] = &ShardStatus{ID: id}
+	}
+	return h
+}
+
+func (h *shardHealth) ready(id, guilds int, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.shards[id]; ok {
+		s.Ready, s.Guilds, s.ReadyAt = true, guilds, now
+	}
+}
+
+func (h *shardHealth) resumed(id int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.shards[id]; ok {
+		s.Resumes++
+	}
+}
+
+// statuses reports the shards by ID, alive tells whether the gateway connection of a shard is up.
+func (h *shardHealth) statuses(alive func(id int) bool) []ShardStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	statuses := make([]ShardStatus, 0, len(h.shards))
+	for _, s := range h.shards {
+		status := *s
+		status.Alive = alive(s.ID)
+		statuses = append(statuses, status)
+	}
+	slices.SortFunc(statuses, func(a, b ShardStatus) int { return a.ID - b.ID })
+	return statuses
+}
+
+// healthy reports whether every shard is ready and connected.
+func healthy(statuses []ShardStatus) bool {
+	for _, s := range statuses {
+		if !s.Ready || !s.Alive {
+			return false
+		}
+	}
+	return true
+}
+
+// ShardStatuses reports the health of the shards run by this process.
+func (b *Bot) ShardStatuses() []ShardStatus {
+	return b.health.statuses(func(id int) bool {
+		i := slices.IndexFunc(b.shards, func(s shard.ShardState) bool { return s.ShardID() == id })
+		return i >= 0 && b.shards[i].Shard.(*state.State).GatewayIsAlive()
+	})
+}
+
+// addShardHandlers adds the handlers to a shard, with the ones tracking its health.
+func (b *Bot) addShardHandlers(sh shard.ShardState, handlers ...any) {
+	s := sh.Shard.(*state.State)
+	id := sh.ShardID()
+	for _, h := range handlers {
+		s.AddHandler(h)
+	}
+	s.AddHandler(func(e *gateway.ReadyEvent) {
+		b.health.ready(id, len(e.Guilds), b.clock.Now())
+		log.Printf("Shard %d/%d ready with %d guilds", id, sh.ID.Shard.NumShards(), len(e.Guilds))
+	})
+	s.AddHandler(func(*gateway.ResumedEvent) {
+		b.health.resumed(id)
+		log.Printf("Shard %d resumed", id)
+	})
+}
+
+// serveStatus serves the expvars and the health of the shards until ctx is done.
+func (b *Bot) serveStatus(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		statuses := b.ShardStatuses()
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy(statuses) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(statuses)
+	})
+	server := &http.Server{
+		Addr:              b.config.StatusAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Serving status on %s", b.config.StatusAddr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
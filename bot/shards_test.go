@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/session/shard"
+)
+
+func TestSelectShards(t *testing.T) {
+	var shards []shard.ShardState
+	for i := 0; i < 4; i++ {
+		data := gateway.IdentifyCommand{}
+		data.SetShard(i, 4)
+		shards = append(shards, shard.ShardState{ID: gateway.Identifier{IdentifyCommand: data}})
+	}
+
+	if selected, err := selectShards(shards, nil); err != nil || len(selected) != 4 {
+		t.Fatalf("Expected every shard to be selected, got %d (%v)", len(selected), err)
+	}
+	selected, err := selectShards(shards, []int{3, 1})
+	if err != nil || len(selected) != 2 || selected[0].ShardID() != 3 || selected[1].ShardID() != 1 {
+		t.Fatalf("Expected shards 3 and 1 to be selected, got %+v (%v)", selected, err)
+	}
+	if _, err := selectShards(shards, []int{4}); err == nil {
+		t.Fatalf("Expected shard 4 to be out of range")
+	}
+}
+
+func TestShardHealth(t *testing.T) {
+	h := newShardHealth([]int{2, 0})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	alive := func(id int) bool { return true }
+
+	statuses := h.statuses(alive)
+	if len(statuses) != 2 || statuses[0].ID != 0 || statuses[1].ID != 2 {
+		t.Fatalf("Expected the shards by ID, got %+v", statuses)
+	}
+	if healthy(statuses) {
+		t.Fatalf("Expected shards that aren't ready to be unhealthy")
+	}
+
+	h.ready(0, 10, now)
+	h.ready(2, 20, now)
+	h.ready(5, 30, now)
+	h.resumed(2)
+	statuses = h.statuses(alive)
+	if !healthy(statuses) || statuses[1].Guilds != 20 || statuses[1].Resumes != 1 || !statuses[1].ReadyAt.Equal(now) {
+		t.Fatalf("Expected the shards to be ready, got %+v", statuses)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected shards of other processes to be ignored, got %+v", statuses)
+	}
+
+	statuses = h.statuses(func(id int) bool { return id != 2 })
+	if healthy(statuses) || statuses[1].Alive {
+		t.Fatalf("Expected a disconnected shard to be unhealthy, got %+v", statuses)
+	}
+}
+
+func TestShardsExpvar(t *testing.T) {
+	defer statusBot.Store(statusBot.Load())
+	for _, ids := range [][]int{{0}, {1, 2}} {
+		// The expvar follows the last started bot
+		statusBot.Store(&Bot{health: newShardHealth(ids)})
+		var statuses []ShardStatus
+		if err := json.Unmarshal([]byte(expvar.Get("shards").String()), &statuses); err != nil || len(statuses) != len(ids) {
+			t.Fatalf("Expected the shards %v, got %+v (%v)", ids, statuses, err)
+		}
+	}
+}
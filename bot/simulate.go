@@ -0,0 +1,204 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/No3371/dc_embed_throttler/bot/internal/fakediscord"
+	"github.com/No3371/dc_embed_throttler/config"
+	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// RoleQuota is a role quota rule, like the ones set with /set_role_quota.
+type RoleQuota struct {
+	RoleID   discord.RoleID
+	Quota    int
+	Priority int
+}
+
+// Hypothesis is the quota setup a recording is simulated with.
+type Hypothesis struct {
+	DefaultQuota int
+	// RoleQuotas apply to every channel of the recording.
+	RoleQuotas []RoleQuota
+	// Messages of exempted users and of users with exempted roles are never throttled.
+	ExemptUsers []discord.UserID
+	ExemptRoles []discord.RoleID
+}
+
+type simulationCount struct {
+	messages   int
+	suppressed int
+	exempted   int
+}
+
+func (c *simulationCount) add(o simulationCount) {
+	c.messages += o.messages
+	c.suppressed += o.suppressed
+	c.exempted += o.exempted
+}
+
+// Simulate replays a recording made by Recorder under the hypothesis and writes how many messages would have been suppressed,
+// per day and per user. Days are counted in storage.DefaultTimezone, like quota resets.
+func Simulate(rd io.Reader, w io.Writer, cfg *config.Config, h Hypothesis) error {
+	simCfg := *cfg
+	simCfg.DefaultQuota = h.DefaultQuota
+	r, err := newReplayer(&simCfg)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	loc, err := loadLocation(storage.DefaultTimezone)
+	if err != nil {
+		return err
+	}
+
+	exemptUsers := make(map[discord.UserID]struct{}, len(h.ExemptUsers))
+	for _, id := range h.ExemptUsers {
+		exemptUsers[id] = struct{}{}
+	}
+	exemptRoles := make(map[discord.RoleID]struct{}, len(h.ExemptRoles))
+	for _, id := range h.ExemptRoles {
+		exemptRoles[id] = struct{}{}
+	}
+	isExempt := func(m *gateway.MessageCreateEvent) bool {
+		if _, ok := exemptUsers[m.Author.ID]; ok {
+			return true
+		}
+		if m.Member != nil {
+			for _, roleID := range m.Member.RoleIDs {
+				if _, ok := exemptRoles[roleID]; ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	counts := make(map[string]map[discord.UserID]*simulationCount)
+	count := func(at time.Time, userID discord.UserID) *simulationCount {
+		day := at.In(loc).Format(time.DateOnly)
+		if counts[day] == nil {
+			counts[day] = make(map[discord.UserID]*simulationCount)
+		}
+		if counts[day][userID] == nil {
+			counts[day][userID] = &simulationCount{}
+		}
+		return counts[day][userID]
+	}
+
+	configured := make(map[discord.ChannelID]struct{})
+	r.before = func(m *gateway.MessageCreateEvent) bool {
+		if _, ok := configured[m.ChannelID]; !ok {
+			configured[m.ChannelID] = struct{}{}
+			for _, rule := range h.RoleQuotas {
+				err := r.store.ConfigureRoleQuota(uint64(m.ChannelID), uint64(rule.RoleID), rule.Quota, rule.Priority)
+				if err != nil {
+					fmt.Fprintf(w, "Error configuring role quota in #%d: %v\n", m.ChannelID, err)
+				}
+			}
+		}
+
+		if m.Author.Bot {
+			return true
+		}
+		c := count(m.Timestamp.Time(), m.Author.ID)
+		c.messages++
+		if isExempt(m) {
+			c.exempted++
+			return false
+		}
+		return true
+	}
+	r.report = func(at time.Time, header string, m *gateway.MessageCreateEvent, calls []fakediscord.Call) {
+		if m == nil || m.Author.Bot {
+			return
+		}
+		// Suppress is the only path that reacts, see Bot.Suppress
+		for _, call := range calls {
+			if call.Method == "React" && call.MessageID == m.ID {
+				count(m.Timestamp.Time(), m.Author.ID).suppressed++
+				break
+			}
+		}
+	}
+
+	err = r.run(rd)
+	if err != nil {
+		return err
+	}
+
+	writeSimulation(w, h, counts)
+	return nil
+}
+
+func writeSimulation(w io.Writer, h Hypothesis, counts map[string]map[discord.UserID]*simulationCount) {
+	fmt.Fprintf(w, "Default quota: %d\n", h.DefaultQuota)
+	for _, rule := range h.RoleQuotas {
+		fmt.Fprintf(w, "Role %d quota: %d (priority %d)\n", rule.RoleID, rule.Quota, rule.Priority)
+	}
+	if len(h.ExemptUsers) > 0 || len(h.ExemptRoles) > 0 {
+		fmt.Fprintf(w, "Exempted users: %v, roles: %v\n", h.ExemptUsers, h.ExemptRoles)
+	}
+
+	days := make([]string, 0, len(counts))
+	users := make(map[discord.UserID]*simulationCount)
+	for day, byUser := range counts {
+		days = append(days, day)
+		for userID, c := range byUser {
+			if users[userID] == nil {
+				users[userID] = &simulationCount{}
+			}
+			users[userID].add(*c)
+		}
+	}
+	sort.Strings(days)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nDAY\tMESSAGES\tSUPPRESSED\tEXEMPTED\tUSERS AFFECTED")
+	total := simulationCount{}
+	for _, day := range days {
+		c := simulationCount{}
+		affected := 0
+		for _, uc := range counts[day] {
+			c.add(*uc)
+			if uc.suppressed > 0 {
+				affected++
+			}
+		}
+		total.add(c)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", day, c.messages, c.suppressed, c.exempted, affected)
+	}
+	tw.Flush()
+
+	userIDs := make([]discord.UserID, 0, len(users))
+	for userID := range users {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		a, b := users[userIDs[i]], users[userIDs[j]]
+		if a.suppressed != b.suppressed {
+			return a.suppressed > b.suppressed
+		}
+		return userIDs[i] < userIDs[j]
+	})
+
+	affected := 0
+	fmt.Fprintln(tw, "\nUSER\tMESSAGES\tSUPPRESSED\tEXEMPTED")
+	for _, userID := range userIDs {
+		c := users[userID]
+		if c.suppressed > 0 {
+			affected++
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\n", userID, c.messages, c.suppressed, c.exempted)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d messages, %d suppressed, %d of %d users affected\n", total.messages, total.suppressed, affected, len(users))
+}
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const maxSummaryTitleLength = 80
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+	">", `\>`,
+	"#", `\#`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+)
+
+// linkSummary renders one line per link of the embeds, so the suppressed previews still tell what the links are.
+func linkSummary(embeds []discord.Embed) string {
+	sb := strings.Builder{}
+	seen := make(map[discord.URL]struct{}, len(embeds))
+	for _, embed := range embeds {
+		if embed.URL != "" {
+			if _, ok := seen[embed.URL]; ok {
+				continue
+			}
+			seen[embed.URL] = struct{}{}
+		}
+
+		parts := make([]string, 0, 3)
+		if title := strings.TrimSpace(embed.Title); title != "" {
+			if r := []rune(title); len(r) > maxSummaryTitleLength {
+				title = string(r[:maxSummaryTitleLength-1]) + "…"
+			}
+			parts = append(parts, "**"+markdownEscaper.Replace(title)+"**")
+		}
+		if embed.Provider != nil && embed.Provider.Name != "" {
+			parts = append(parts, markdownEscaper.Replace(embed.Provider.Name))
+		}
+		if u, err := url.Parse(embed.URL); err == nil && u.Hostname() != "" {
+			parts = append(parts, "`"+strings.TrimPrefix(u.Hostname(), "www.")+"`")
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		line := "-# 🔗 " + strings.Join(parts, " · ") + "\n"
+		if sb.Len()+len(line) > 2000 {
+			break
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+func (b *Bot) postLinkSummary(m *discord.Message, embeds []discord.Embed) {
+	enabled, err := b.storage.IsChannelLinkSummary(uint64(m.ChannelID))
+	if err != nil {
+		log.Printf("Error checking link summary status: %v", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	summary := linkSummary(embeds)
+	if summary == "" {
+		return
+	}
+
+	err = b.replyTracked(m, summary, discord.SuppressEmbeds)
+	if err != nil {
+		log.Printf("Error posting link summary for %d: %v", m.ID, err)
+	}
+}
+
+// replyTracked replies to the message, or edits the reply the bot has already posted for it.
+// The reply is deleted along with the message.
+func (b *Bot) replyTracked(m *discord.Message, content string, flags discord.MessageFlags) error {
+	replyID, err := b.storage.GetBotReply(uint64(m.ID))
+	if err == nil {
+		_, err = b.client.EditMessageComplex(m.ChannelID, discord.MessageID(replyID), api.EditMessageData{
+			Content: option.NewNullableString(content),
+			Flags:   &flags,
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("Error editing reply %d, posting a new one: %v", replyID, err)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	reply, err := b.client.SendMessageComplex(m.ChannelID, api.SendMessageData{
+		Content:         content,
+		Reference:       &discord.MessageReference{MessageID: m.ID},
+		AllowedMentions: &api.AllowedMentions{Parse: []api.AllowedMentionType{}},
+		Flags:           flags,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.storage.SetBotReply(uint64(m.ID), uint64(m.ChannelID), uint64(reply.ID))
+}
+
+func (b *Bot) handleToggleLinkSummary(i *gateway.InteractionCreateEvent) error {
+	enabled, err := b.storage.IsChannelLinkSummary(uint64(i.ChannelID))
+	if err != nil {
+		return err
+	}
+
+	err = b.storage.SetChannelLinkSummary(uint64(i.ChannelID), !enabled)
+	if err != nil {
+		return err
+	}
+
+	key := "toggle_link_summary.disabled"
+	if !enabled {
+		key = "toggle_link_summary.enabled"
+	}
+	respd := api.InteractionResponseData{
+		Content: option.NewNullableString(tr(interactionLanguage(i), key)),
+		Flags:   discord.EphemeralMessage,
+	}
+	return b.client.RespondInteraction(i.ID, i.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &respd,
+	})
+}
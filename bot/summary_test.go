@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestLinkSummary(t *testing.T) {
+	embeds := []discord.Embed{
+		{
+			Title:    "Some *bold* claim",
+			URL:      "https://www.example.com/article?id=1",
+			Provider: &discord.EmbedProvider{Name: "Example News"},
+		},
+		{
+			Title: "Duplicate",
+			URL:   "https://www.example.com/article?id=1",
+		},
+		{
+			URL: "https://video.example.org/v/1",
+		},
+		{
+			Title: strings.Repeat("a", 200),
+		},
+		{},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(linkSummary(embeds), "\n"), "\n")
+	expected := []string{
+		"-# 🔗 **Some \\*bold\\* claim** · Example News · `example.com`",
+		"-# 🔗 `video.example.org`",
+		"-# 🔗 **" + strings.Repeat("a", maxSummaryTitleLength-1) + "…**",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %q", len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
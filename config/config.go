@@ -1,6 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -11,6 +15,27 @@ type Config struct {
 	DefaultEnabled bool
 	DatabasePath   string
 	UpdateCommands bool
+	// RecordPath is where received gateway events are recorded, recording is disabled if empty.
+	RecordPath string
+	// DevGuilds are where commands are registered instead of globally, experimental ones included.
+	DevGuilds []uint64
+	// ExperimentalGuilds are where experimental commands are registered, besides the global commands.
+	ExperimentalGuilds []uint64
+	// RateLimitBurst is how many times a user can use a command at once, rate limiting is disabled if zero.
+	RateLimitBurst int
+	// RateLimitPeriod is how long it takes for a user to be able to use a command RateLimitBurst times again.
+	RateLimitPeriod time.Duration
+	// InteractionsAddr is where interactions are received over HTTP, they are only received from the gateway if empty.
+	InteractionsAddr string
+	// InteractionsPublicKey is the hex-encoded public key of the application, the requests are signed with.
+	InteractionsPublicKey string
+	// ShardCount is how many shards the bot has in total, the count Discord recommends is used if zero.
+	ShardCount int
+	// ShardIDs are the shards run by this process, all of them if empty. ShardCount must be set with them,
+	// so that the processes agree on the count.
+	ShardIDs []int
+	// StatusAddr is where the expvars and the health of the shards are served, they aren't served if empty.
+	StatusAddr string
 }
 
 func LoadConfig() (*Config, error) {
@@ -32,15 +57,60 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("default_enabled", false)
 	viper.SetDefault("database_path", "bot.db")
 	viper.SetDefault("update_commands", false)
+	viper.SetDefault("record_path", "")
+	viper.SetDefault("dev_guilds", []string{})
+	viper.SetDefault("experimental_guilds", []string{})
+	viper.SetDefault("rate_limit_burst", 5)
+	viper.SetDefault("rate_limit_period", "30s")
+	viper.SetDefault("interactions_addr", "")
+	viper.SetDefault("interactions_public_key", "")
+	viper.SetDefault("shard_count", 0)
+	viper.SetDefault("shard_ids", []int{})
+	viper.SetDefault("status_addr", "")
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
 	}
+	devGuilds, err := parseIDs("dev_guilds")
+	if err != nil {
+		return nil, err
+	}
+	experimentalGuilds, err := parseIDs("experimental_guilds")
+	if err != nil {
+		return nil, err
+	}
+	shardCount, shardIDs := viper.GetInt("shard_count"), viper.GetIntSlice("shard_ids")
+	if len(shardIDs) > 0 && shardCount <= 0 {
+		return nil, fmt.Errorf("shard_ids %v set without shard_count", shardIDs)
+	}
 	return &Config{
-		Token:          viper.GetString("token"),
-		DefaultQuota:   viper.GetInt("default_quota"),
-		DefaultEnabled: viper.GetBool("default_enabled"),
-		DatabasePath:   viper.GetString("database_path"),
-		UpdateCommands: viper.GetBool("update_commands"),
+		Token:                 viper.GetString("token"),
+		DefaultQuota:          viper.GetInt("default_quota"),
+		DefaultEnabled:        viper.GetBool("default_enabled"),
+		DatabasePath:          viper.GetString("database_path"),
+		UpdateCommands:        viper.GetBool("update_commands"),
+		RecordPath:            viper.GetString("record_path"),
+		DevGuilds:             devGuilds,
+		ExperimentalGuilds:    experimentalGuilds,
+		RateLimitBurst:        viper.GetInt("rate_limit_burst"),
+		RateLimitPeriod:       viper.GetDuration("rate_limit_period"),
+		InteractionsAddr:      viper.GetString("interactions_addr"),
+		InteractionsPublicKey: viper.GetString("interactions_public_key"),
+		ShardCount:            shardCount,
+		ShardIDs:              shardIDs,
+		StatusAddr:            viper.GetString("status_addr"),
 	}, nil
 }
+
+// parseIDs reads a list of snowflakes, given as numbers or strings.
+func parseIDs(key string) ([]uint64, error) {
+	var ids []uint64
+	for _, s := range viper.GetStringSlice(key) {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", key, s, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
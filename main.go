@@ -2,33 +2,91 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/No3371/dc_embed_throttler/bot"
+	"github.com/No3371/dc_embed_throttler/clock"
 	"github.com/No3371/dc_embed_throttler/config"
 	"github.com/No3371/dc_embed_throttler/storage"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/spf13/pflag"
 )
 
 func main() {
+	quota := pflag.Int("quota", -1, "simulate: default quota, the configured one if negative")
+	roleQuotas := pflag.StringSlice("role_quota", nil, "simulate: role quota rule as role_id:quota:priority")
+	exemptUsers := pflag.StringSlice("exempt_user", nil, "simulate: IDs of users that are never throttled")
+	exemptRoles := pflag.StringSlice("exempt_role", nil, "simulate: IDs of roles that are never throttled")
+	guild := pflag.Uint64("guild", 0, "commands: ID of the guild whose commands are managed, the global commands if 0")
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	switch pflag.Arg(0) {
+	case "replay":
+		// replay <recording>: print what the bot would do with the recorded events, without connecting to Discord
+		f, err := os.Open(pflag.Arg(1))
+		if err != nil {
+			log.Fatalf("Failed to open recording: %v", err)
+		}
+		defer f.Close()
+		if err := bot.Replay(f, os.Stdout, cfg); err != nil {
+			log.Fatalf("Failed to replay: %v", err)
+		}
+		return
+	case "simulate":
+		// simulate <recording>: count the suppressions the recorded events would get with the quotas given by flags
+		h, err := parseHypothesis(cfg, *quota, *roleQuotas, *exemptUsers, *exemptRoles)
+		if err != nil {
+			log.Fatalf("Invalid simulation: %v", err)
+		}
+		f, err := os.Open(pflag.Arg(1))
+		if err != nil {
+			log.Fatalf("Failed to open recording: %v", err)
+		}
+		defer f.Close()
+		if err := bot.Simulate(f, os.Stdout, cfg, h); err != nil {
+			log.Fatalf("Failed to simulate: %v", err)
+		}
+		return
+	case "commands":
+		// commands list|diff|delete [names...]: manage the registered commands of the global or --guild scope,
+		// delete without names deletes every command of the scope
+		if err := bot.ManageCommands(os.Stdout, cfg, pflag.Arg(1), discord.GuildID(*guild), pflag.Args()[min(2, pflag.NArg()):]); err != nil {
+			log.Fatalf("Failed to manage commands: %v", err)
+		}
+		return
+	}
+
 	// Initialize storage
-	store, err := storage.NewSQLiteStorage(cfg.DatabasePath)
+	store, err := storage.NewSQLiteStorage(cfg.DatabasePath, clock.System)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 	defer store.Close()
 	ctx := contextWithSigterm(context.Background())
 
+	if pflag.Arg(0) == "interactions" {
+		// interactions: serve the interactions endpoint without connecting to the gateway, so it can be scaled
+		// apart from the shards
+		log.Println("Serving interactions...")
+		if err := bot.ServeInteractions(ctx, cfg, store, clock.System); err != nil {
+			log.Fatalf("Failed to serve interactions: %v", err)
+		}
+		return
+	}
+
 	// Create and start bot
-	b, err := bot.NewBot(cfg, store)
+	b, err := bot.NewBot(cfg, store, clock.System)
 	if err != nil {
 		log.Fatalf("Failed to create bot: %v", err)
 	}
@@ -41,6 +99,52 @@ func main() {
 	<-ctx.Done()
 }
 
+func parseHypothesis(cfg *config.Config, quota int, roleQuotas, exemptUsers, exemptRoles []string) (bot.Hypothesis, error) {
+	h := bot.Hypothesis{DefaultQuota: cfg.DefaultQuota}
+	if quota >= 0 {
+		h.DefaultQuota = quota
+	}
+
+	for _, rule := range roleQuotas {
+		parts := strings.Split(rule, ":")
+		if len(parts) != 3 {
+			return h, fmt.Errorf("invalid role quota %q, expected role_id:quota:priority", rule)
+		}
+		roleID, err := discord.ParseSnowflake(parts[0])
+		if err != nil {
+			return h, fmt.Errorf("invalid role quota %q: %w", rule, err)
+		}
+		q, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return h, fmt.Errorf("invalid role quota %q: %w", rule, err)
+		}
+		priority, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return h, fmt.Errorf("invalid role quota %q: %w", rule, err)
+		}
+		if q < 0 || priority < 0 {
+			return h, fmt.Errorf("invalid role quota %q, the quota and priority can't be negative", rule)
+		}
+		h.RoleQuotas = append(h.RoleQuotas, bot.RoleQuota{RoleID: discord.RoleID(roleID), Quota: q, Priority: priority})
+	}
+
+	for _, id := range exemptUsers {
+		userID, err := discord.ParseSnowflake(id)
+		if err != nil {
+			return h, fmt.Errorf("invalid user %q: %w", id, err)
+		}
+		h.ExemptUsers = append(h.ExemptUsers, discord.UserID(userID))
+	}
+	for _, id := range exemptRoles {
+		roleID, err := discord.ParseSnowflake(id)
+		if err != nil {
+			return h, fmt.Errorf("invalid role %q: %w", id, err)
+		}
+		h.ExemptRoles = append(h.ExemptRoles, discord.RoleID(roleID))
+	}
+	return h, nil
+}
+
 // https://gist.github.com/matejb/87064825093c42c1e76e7175665d9a9b
 func contextWithSigterm(ctx context.Context) context.Context {
 	ctxWithCancel, cancel := context.WithCancel(ctx)
@@ -2,10 +2,13 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/No3371/dc_embed_throttler/clock"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -13,6 +16,10 @@ type User struct {
 	UserID     uint64
 	Hinted     int
 	NextHintAt time.Time
+	// HintMode is the user's choice of how to be hinted, empty if the user has not chosen.
+	HintMode string
+	// Locale is the language of the user's Discord client, as last seen in an interaction.
+	Locale string
 }
 
 type Storage interface {
@@ -27,19 +34,74 @@ type Storage interface {
 	SetChannelSuppressBot(channelID uint64, suppressBot bool) error
 	GetUser(userID uint64) (User, error)
 	SetNextHintAt(userID uint64, nextHintAt time.Time) error
+	SetUserHintMode(userID uint64, mode string) error
+	SetUserLocale(userID uint64, locale string) error
 	GetAllRoleQuotas(channelID uint64) ([]RoleQuota, error)
 	GetQuotaByRoles(channelID uint64, roleIDs []uint64) (int, error)
 	ConfigureRoleQuota(channelID uint64, roleID uint64, quota int, priority int) error
+	RemoveRoleQuota(channelID uint64, roleID uint64) (bool, error)
+	CopyRoleQuotas(fromChannelID uint64, toChannelIDs []uint64) (int, error)
+	GetEmbedCosts(guildID uint64) ([]EmbedCost, error)
+	SetEmbedCost(guildID uint64, embedType string, provider string, cost int) error
+	GetAttachmentQuota(channelID uint64) (int, error)
+	SetAttachmentQuota(channelID uint64, quota int) error
+	ResetAttachmentUsage(userID, channelID uint64) error
+	GetAttachmentUsage(userID, channelID uint64) (int, error)
+	IncreaseAttachmentUsage(userID, channelID uint64, delta int) (int, error)
+	IsChannelLinkSummary(channelID uint64) (bool, error)
+	SetChannelLinkSummary(channelID uint64, linkSummary bool) error
+	GetBotReply(messageID uint64) (uint64, error)
+	SetBotReply(messageID, channelID, replyID uint64) error
+	DeleteBotReply(messageID uint64) (uint64, error)
+	GetLinkRewrites(guildID uint64) ([]LinkRewrite, error)
+	SetLinkRewrite(guildID uint64, fromHost, toHost string) error
+	RemoveLinkRewrite(guildID uint64, fromHost string) error
+	GetDuplicateWindow(channelID uint64) (time.Duration, error)
+	SetDuplicateWindow(channelID uint64, window time.Duration) error
+	FindRecentURL(channelID uint64, url string, since time.Time) (uint64, error)
+	RecordRecentURL(channelID uint64, url string, messageID uint64, postedAt time.Time) error
+	PruneRecentURLs(before time.Time) error
+	GetBurstLimit(channelID uint64) (int, time.Duration, error)
+	SetBurstLimit(channelID uint64, limit int, period time.Duration) error
+	GetGuildTimezone(guildID uint64) (string, error)
+	SetGuildTimezone(guildID uint64, timezone string) error
+	GetGuildLocale(guildID uint64) (string, error)
+	SetGuildLocale(guildID uint64, locale string) error
+	GetGuildHintDelivery(guildID uint64) (string, error)
+	SetGuildHintDelivery(guildID uint64, delivery string) error
+	GetHintTemplate(guildID uint64, reason string) (string, error)
+	SetHintTemplate(guildID uint64, reason string, template string) error
+	GetChannelSchedules(channelID uint64) ([]Schedule, error)
+	AddChannelSchedule(channelID uint64, schedule Schedule) (int64, error)
+	RemoveChannelSchedule(channelID uint64, scheduleID int64) (bool, error)
+	IsChannelScheduledAt(channelID uint64, at time.Time) (bool, error)
+	IsChannelShadow(channelID uint64) (bool, error)
+	SetChannelShadow(channelID uint64, shadow bool) error
+	RecordShadowDecision(decision ShadowDecision) error
+	GetShadowCounts(channelID uint64) (map[string]int, error)
+	GetShadowSuppressedUsers(channelID uint64, limit int) ([]ShadowUser, error)
+	ResetShadowDecisions(channelID uint64) error
+	GetShadowQuotaUsage(userID, channelID uint64) (int, error)
+	IncreaseShadowQuotaUsage(userID, channelID uint64, delta int) (int, error)
+	GetShadowAttachmentUsage(userID, channelID uint64) (int, error)
+	IncreaseShadowAttachmentUsage(userID, channelID uint64, delta int) (int, error)
+	GetBotAdminRoles(guildID uint64) ([]uint64, error)
+	AddBotAdminRole(guildID, roleID uint64) error
+	RemoveBotAdminRole(guildID, roleID uint64) (bool, error)
 	Close() error
 }
 
+// DefaultTimezone is used by guilds that have not configured their timezone.
+const DefaultTimezone = "Asia/Taipei"
+
 type SQLiteStorage struct {
-	db *sql.DB
+	db    *sql.DB
+	clock clock.Clock
 }
 
 var _ Storage = &SQLiteStorage{}
 
-func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
+func NewSQLiteStorage(dbPath string, clk clock.Clock) (*SQLiteStorage, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, err
@@ -71,55 +133,229 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 			priority INTEGER DEFAULT 0,
 			PRIMARY KEY (role_id, channel_id)
 		);
+		CREATE TABLE IF NOT EXISTS embed_cost (
+			guild_id INTEGER,
+			embed_type TEXT,
+			provider TEXT DEFAULT '',
+			cost INTEGER DEFAULT 1,
+			PRIMARY KEY (guild_id, embed_type, provider)
+		);
+		CREATE TABLE IF NOT EXISTS attachment_usage (
+			user_id INTEGER,
+			channel_id INTEGER,
+			count INTEGER DEFAULT 0,
+			last_reset_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, channel_id)
+		);
+		CREATE TABLE IF NOT EXISTS bot_reply (
+			message_id INTEGER PRIMARY KEY,
+			channel_id INTEGER,
+			reply_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS link_rewrite (
+			guild_id INTEGER,
+			from_host TEXT,
+			to_host TEXT,
+			PRIMARY KEY (guild_id, from_host)
+		);
+		CREATE TABLE IF NOT EXISTS recent_url (
+			channel_id INTEGER,
+			url TEXT,
+			message_id INTEGER,
+			posted_at DATETIME,
+			PRIMARY KEY (channel_id, url)
+		);
+		CREATE TABLE IF NOT EXISTS guild_settings (
+			guild_id INTEGER PRIMARY KEY,
+			timezone TEXT DEFAULT '` + DefaultTimezone + `'
+		);
+		CREATE TABLE IF NOT EXISTS channel_schedule (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel_id INTEGER,
+			weekday INTEGER DEFAULT -1,
+			start_minute INTEGER,
+			end_minute INTEGER
+		);
+		CREATE TABLE IF NOT EXISTS hint_template (
+			guild_id INTEGER,
+			reason TEXT,
+			template TEXT,
+			PRIMARY KEY (guild_id, reason)
+		);
+		CREATE TABLE IF NOT EXISTS shadow_decision (
+			channel_id INTEGER,
+			message_id INTEGER,
+			user_id INTEGER,
+			decision TEXT,
+			cost INTEGER,
+			decided_at DATETIME,
+			PRIMARY KEY (channel_id, message_id)
+		);
+		CREATE TABLE IF NOT EXISTS shadow_count (
+			channel_id INTEGER,
+			decision TEXT,
+			count INTEGER DEFAULT 0,
+			PRIMARY KEY (channel_id, decision)
+		);
+		CREATE TABLE IF NOT EXISTS shadow_quota_usage (
+			user_id INTEGER,
+			channel_id INTEGER,
+			count INTEGER DEFAULT 0,
+			last_reset_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, channel_id)
+		);
+		CREATE TABLE IF NOT EXISTS shadow_attachment_usage (
+			user_id INTEGER,
+			channel_id INTEGER,
+			count INTEGER DEFAULT 0,
+			last_reset_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, channel_id)
+		);
+		CREATE TABLE IF NOT EXISTS bot_admin_role (
+			guild_id INTEGER,
+			role_id INTEGER,
+			PRIMARY KEY (guild_id, role_id)
+		);
 	`)
 	if err != nil {
 		return nil, err
 	}
 
-	return &SQLiteStorage{db: db}, nil
+	err = addColumnIfNotExists(db, "channel_settings", "attachment_quota", "INTEGER DEFAULT -1")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "channel_settings", "link_summary", "BOOLEAN DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "channel_settings", "duplicate_window", "INTEGER DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "channel_settings", "burst_limit", "INTEGER DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "channel_settings", "burst_period", "INTEGER DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "channel_settings", "shadow", "BOOLEAN DEFAULT 0")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "user", "hint_mode", "TEXT DEFAULT ''")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "user", "locale", "TEXT DEFAULT ''")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "guild_settings", "locale", "TEXT DEFAULT ''")
+	if err != nil {
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "guild_settings", "hint_delivery", "TEXT DEFAULT 'dm'")
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db, clock: clk}, nil
 }
 
-func (s *SQLiteStorage) TryResetQuotaOnNextDay(userID, channelID uint64) error {
-	taipeiTime := time.Now().UTC().Add(time.Hour * 8)
+// addColumnIfNotExists migrates tables created by older versions.
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// The usage tables (quota_usage, attachment_usage) share the same layout and daily reset rules.
+
+// taipeiTime is the time in UTC+8 expressed as UTC, so truncating it to a day gives the Taipei midnight.
+func (s *SQLiteStorage) taipeiTime() time.Time {
+	return s.clock.Now().UTC().Add(time.Hour * 8)
+}
+
+func (s *SQLiteStorage) tryResetUsageOnNextDay(table string, userID, channelID uint64) error {
+	taipeiTime := s.taipeiTime()
 	taipeiTimeMidnight := taipeiTime.Truncate(time.Hour * 24)
-	_, err := s.db.Exec(`UPDATE quota_usage SET count = 0, last_reset_at = ?
+	_, err := s.db.Exec(`UPDATE `+table+` SET count = 0, last_reset_at = ?
 WHERE user_id = ? AND channel_id = ? AND last_reset_at < ?`, taipeiTime, userID, channelID, taipeiTimeMidnight)
 	return err
 }
 
-func (s *SQLiteStorage) ResetQuotaUsage(userID, channelID uint64) error {
-	taipeiTime := time.Now().UTC().Add(time.Hour * 8)
-	_, err := s.db.Exec(`INSERT INTO quota_usage (user_id, channel_id, last_reset_at) VALUES (?, ?, ?)
+func (s *SQLiteStorage) resetUsage(table string, userID, channelID uint64) error {
+	taipeiTime := s.taipeiTime()
+	_, err := s.db.Exec(`INSERT INTO `+table+` (user_id, channel_id, last_reset_at) VALUES (?, ?, ?)
 ON CONFLICT(user_id, channel_id) DO UPDATE SET count = 0, last_reset_at = ?`, userID, channelID, taipeiTime, taipeiTime)
 	return err
 }
 
-func (s *SQLiteStorage) GetQuotaUsage(userID, channelID uint64) (int, error) {
-	err := s.TryResetQuotaOnNextDay(userID, channelID)
+func (s *SQLiteStorage) getUsage(table string, userID, channelID uint64) (int, error) {
+	err := s.tryResetUsageOnNextDay(table, userID, channelID)
 	if err != nil {
 		return 0, err
 	}
 
 	var count int
-	err = s.db.QueryRow("SELECT count FROM quota_usage WHERE user_id = ? AND channel_id = ?", userID, channelID).Scan(&count)
+	err = s.db.QueryRow("SELECT count FROM "+table+" WHERE user_id = ? AND channel_id = ?", userID, channelID).Scan(&count)
 	return count, err
 }
 
-func (s *SQLiteStorage) IncreaseQuotaUsage(userID, channelID uint64, delta int) (int, error) {
+func (s *SQLiteStorage) increaseUsage(table string, userID, channelID uint64, delta int) (int, error) {
 	var count int
 	err := s.db.QueryRow(`
-INSERT INTO quota_usage (user_id, channel_id, count)
-VALUES (?, ?, 0)
+INSERT INTO `+table+` (user_id, channel_id, count, last_reset_at)
+VALUES (?, ?, ?, ?)
 ON CONFLICT(user_id, channel_id)
 DO UPDATE SET count = count + ?
 RETURNING count
-`, userID, channelID, delta).Scan(&count)
+`, userID, channelID, delta, s.taipeiTime(), delta).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
+func (s *SQLiteStorage) TryResetQuotaOnNextDay(userID, channelID uint64) error {
+	return s.tryResetUsageOnNextDay("quota_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) ResetQuotaUsage(userID, channelID uint64) error {
+	return s.resetUsage("quota_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) GetQuotaUsage(userID, channelID uint64) (int, error) {
+	return s.getUsage("quota_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) IncreaseQuotaUsage(userID, channelID uint64, delta int) (int, error) {
+	return s.increaseUsage("quota_usage", userID, channelID, delta)
+}
+
+func (s *SQLiteStorage) ResetAttachmentUsage(userID, channelID uint64) error {
+	return s.resetUsage("attachment_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) GetAttachmentUsage(userID, channelID uint64) (int, error) {
+	return s.getUsage("attachment_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) IncreaseAttachmentUsage(userID, channelID uint64, delta int) (int, error) {
+	return s.increaseUsage("attachment_usage", userID, channelID, delta)
+}
+
 func (s *SQLiteStorage) DecreaseQuotaUsage(userID, channelID uint64, amount int) (int, error) {
 	var count int
 	err := s.db.QueryRow(`
@@ -164,13 +400,107 @@ func (s *SQLiteStorage) SetChannelSuppressBot(channelID uint64, suppressBot bool
 	`, channelID, suppressBot, suppressBot)
 	return err
 }
+
+// GetAttachmentQuota returns -1 if attachment throttling is disabled for the channel.
+func (s *SQLiteStorage) GetAttachmentQuota(channelID uint64) (int, error) {
+	var quota int
+	err := s.db.QueryRow("SELECT attachment_quota FROM channel_settings WHERE channel_id = ?", channelID).Scan(&quota)
+	if err == sql.ErrNoRows {
+		return -1, nil
+	}
+	return quota, err
+}
+
+func (s *SQLiteStorage) SetAttachmentQuota(channelID uint64, quota int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO channel_settings (channel_id, attachment_quota)
+		VALUES (?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET attachment_quota = ?
+	`, channelID, quota, quota)
+	return err
+}
+
+func (s *SQLiteStorage) IsChannelLinkSummary(channelID uint64) (bool, error) {
+	var linkSummary bool
+	err := s.db.QueryRow("SELECT link_summary FROM channel_settings WHERE channel_id = ?", channelID).Scan(&linkSummary)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return linkSummary, err
+}
+
+func (s *SQLiteStorage) SetChannelLinkSummary(channelID uint64, linkSummary bool) error {
+	_, err := s.db.Exec(`
+		INSERT INTO channel_settings (channel_id, link_summary)
+		VALUES (?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET link_summary = ?
+	`, channelID, linkSummary, linkSummary)
+	return err
+}
+
+// GetBotReply returns the reply the bot has posted for a message, sql.ErrNoRows if there is none.
+func (s *SQLiteStorage) GetBotReply(messageID uint64) (uint64, error) {
+	var replyID uint64
+	err := s.db.QueryRow("SELECT reply_id FROM bot_reply WHERE message_id = ?", messageID).Scan(&replyID)
+	return replyID, err
+}
+
+func (s *SQLiteStorage) SetBotReply(messageID, channelID, replyID uint64) error {
+	_, err := s.db.Exec(`INSERT INTO bot_reply (message_id, channel_id, reply_id) VALUES (?, ?, ?)
+	ON CONFLICT(message_id) DO UPDATE SET reply_id = ?`, messageID, channelID, replyID, replyID)
+	return err
+}
+
+// DeleteBotReply forgets the reply the bot has posted for a message and returns it, sql.ErrNoRows if there is none.
+func (s *SQLiteStorage) DeleteBotReply(messageID uint64) (uint64, error) {
+	var replyID uint64
+	err := s.db.QueryRow("DELETE FROM bot_reply WHERE message_id = ? RETURNING reply_id", messageID).Scan(&replyID)
+	return replyID, err
+}
+
+// LinkRewrite replaces the host of posted links, e.g. x.com to fixupx.com.
+type LinkRewrite struct {
+	FromHost string
+	ToHost   string
+}
+
+func (s *SQLiteStorage) GetLinkRewrites(guildID uint64) ([]LinkRewrite, error) {
+	var rewrites []LinkRewrite
+	rows, err := s.db.Query("SELECT from_host, to_host FROM link_rewrite WHERE guild_id = ? ORDER BY from_host", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rewrite LinkRewrite
+		err := rows.Scan(&rewrite.FromHost, &rewrite.ToHost)
+		if err != nil {
+			return nil, err
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	return rewrites, rows.Err()
+}
+
+func (s *SQLiteStorage) SetLinkRewrite(guildID uint64, fromHost, toHost string) error {
+	_, err := s.db.Exec(`INSERT INTO link_rewrite (guild_id, from_host, to_host) VALUES (?, ?, ?)
+	ON CONFLICT(guild_id, from_host) DO UPDATE SET to_host = ?`, guildID, fromHost, toHost, toHost)
+	return err
+}
+
+func (s *SQLiteStorage) RemoveLinkRewrite(guildID uint64, fromHost string) error {
+	_, err := s.db.Exec("DELETE FROM link_rewrite WHERE guild_id = ? AND from_host = ?", guildID, fromHost)
+	return err
+}
+
 func (s *SQLiteStorage) Close() error {
 	return s.db.Close()
 }
 
 func (s *SQLiteStorage) GetUser(userID uint64) (User, error) {
 	var user User
-	err := s.db.QueryRow("SELECT hinted, next_hint_at FROM user WHERE user_id = ?", userID).Scan(&user.Hinted, &user.NextHintAt)
+	err := s.db.QueryRow("SELECT hinted, next_hint_at, hint_mode, locale FROM user WHERE user_id = ?", userID).Scan(&user.Hinted, &user.NextHintAt, &user.HintMode, &user.Locale)
 	user.UserID = userID
 	return user, err
 }
@@ -181,6 +511,20 @@ func (s *SQLiteStorage) SetNextHintAt(userID uint64, nextHintAt time.Time) error
 	return err
 }
 
+// SetUserHintMode creates the user as due for a hint, like GetUser reports for unknown users.
+func (s *SQLiteStorage) SetUserHintMode(userID uint64, mode string) error {
+	_, err := s.db.Exec(`INSERT INTO user (user_id, next_hint_at, hint_mode) VALUES (?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET hint_mode = ?`, userID, time.Time{}, mode, mode)
+	return err
+}
+
+// SetUserLocale creates the user as due for a hint, like GetUser reports for unknown users.
+func (s *SQLiteStorage) SetUserLocale(userID uint64, locale string) error {
+	_, err := s.db.Exec(`INSERT INTO user (user_id, next_hint_at, locale) VALUES (?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET locale = ?`, userID, time.Time{}, locale, locale)
+	return err
+}
+
 func (s *SQLiteStorage) IncreaseHinted(userID uint64) error {
 	_, err := s.db.Exec(`INSERT INTO user (user_id, hinted) VALUES (?, 1)
 	ON CONFLICT(user_id) DO UPDATE SET hinted = hinted + 1`, userID)
@@ -229,8 +573,514 @@ func (s *SQLiteStorage) GetQuotaByRoles(channelID uint64, roleIDs []uint64) (int
 	return quota, err
 }
 
+// ErrNegativeRoleQuota is returned when a role quota or its priority is negative.
+var ErrNegativeRoleQuota = errors.New("role quota and priority can't be negative")
+
 func (s *SQLiteStorage) ConfigureRoleQuota(channelID uint64, roleID uint64, quota int, priority int) error {
+	if quota < 0 || priority < 0 {
+		return ErrNegativeRoleQuota
+	}
 	_, err := s.db.Exec(`INSERT INTO role (role_id, channel_id, quota, priority) VALUES (?, ?, ?, ?)
 	ON CONFLICT(role_id, channel_id) DO UPDATE SET quota = ?, priority = ?`, roleID, channelID, quota, priority, quota, priority)
 	return err
 }
+
+// RemoveRoleQuota reports whether the role had a quota in the channel.
+func (s *SQLiteStorage) RemoveRoleQuota(channelID uint64, roleID uint64) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM role WHERE channel_id = ? AND role_id = ?", channelID, roleID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CopyRoleQuotas copies every role quota of a channel to the other channels, replacing their quotas of the same roles.
+// It returns how many quotas were copied to each channel.
+func (s *SQLiteStorage) CopyRoleQuotas(fromChannelID uint64, toChannelIDs []uint64) (int, error) {
+	quotas, err := s.GetAllRoleQuotas(fromChannelID)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, channelID := range toChannelIDs {
+		if channelID == fromChannelID {
+			continue
+		}
+		for _, quota := range quotas {
+			if quota.Quota < 0 || quota.Priority < 0 {
+				return 0, ErrNegativeRoleQuota
+			}
+			_, err := tx.Exec(`INSERT INTO role (role_id, channel_id, quota, priority) VALUES (?, ?, ?, ?)
+			ON CONFLICT(role_id, channel_id) DO UPDATE SET quota = ?, priority = ?`,
+				quota.RoleID, channelID, quota.Quota, quota.Priority, quota.Quota, quota.Priority)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(quotas), tx.Commit()
+}
+
+// EmbedCost is the quota weight of an embed type, optionally narrowed down to a provider.
+// An empty Provider applies to every provider of the type.
+type EmbedCost struct {
+	EmbedType string
+	Provider  string
+	Cost      int
+}
+
+func (s *SQLiteStorage) GetEmbedCosts(guildID uint64) ([]EmbedCost, error) {
+	var costs []EmbedCost
+	rows, err := s.db.Query("SELECT embed_type, provider, cost FROM embed_cost WHERE guild_id = ? ORDER BY embed_type, provider", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cost EmbedCost
+		err := rows.Scan(&cost.EmbedType, &cost.Provider, &cost.Cost)
+		if err != nil {
+			return nil, err
+		}
+		costs = append(costs, cost)
+	}
+	return costs, rows.Err()
+}
+
+// SetEmbedCost configures the cost of an embed type. A negative cost removes the entry.
+func (s *SQLiteStorage) SetEmbedCost(guildID uint64, embedType string, provider string, cost int) error {
+	if cost < 0 {
+		_, err := s.db.Exec(`DELETE FROM embed_cost WHERE guild_id = ? AND embed_type = ? AND provider = ?`, guildID, embedType, provider)
+		return err
+	}
+	_, err := s.db.Exec(`INSERT INTO embed_cost (guild_id, embed_type, provider, cost) VALUES (?, ?, ?, ?)
+	ON CONFLICT(guild_id, embed_type, provider) DO UPDATE SET cost = ?`, guildID, embedType, provider, cost, cost)
+	return err
+}
+
+// GetDuplicateWindow returns 0 if duplicate link detection is disabled for the channel.
+func (s *SQLiteStorage) GetDuplicateWindow(channelID uint64) (time.Duration, error) {
+	var seconds int64
+	err := s.db.QueryRow("SELECT duplicate_window FROM channel_settings WHERE channel_id = ?", channelID).Scan(&seconds)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return time.Duration(seconds) * time.Second, err
+}
+
+func (s *SQLiteStorage) SetDuplicateWindow(channelID uint64, window time.Duration) error {
+	seconds := int64(window / time.Second)
+	_, err := s.db.Exec(`
+		INSERT INTO channel_settings (channel_id, duplicate_window)
+		VALUES (?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET duplicate_window = ?
+	`, channelID, seconds, seconds)
+	return err
+}
+
+// FindRecentURL returns the message the url has been posted in since the given time, sql.ErrNoRows if there is none.
+func (s *SQLiteStorage) FindRecentURL(channelID uint64, url string, since time.Time) (uint64, error) {
+	var messageID uint64
+	err := s.db.QueryRow("SELECT message_id FROM recent_url WHERE channel_id = ? AND url = ? AND posted_at >= ?", channelID, url, since.UTC()).Scan(&messageID)
+	return messageID, err
+}
+
+func (s *SQLiteStorage) RecordRecentURL(channelID uint64, url string, messageID uint64, postedAt time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO recent_url (channel_id, url, message_id, posted_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT(channel_id, url) DO UPDATE SET message_id = ?, posted_at = ?`, channelID, url, messageID, postedAt.UTC(), messageID, postedAt.UTC())
+	return err
+}
+
+// PruneRecentURLs forgets urls posted before the given time.
+func (s *SQLiteStorage) PruneRecentURLs(before time.Time) error {
+	_, err := s.db.Exec("DELETE FROM recent_url WHERE posted_at < ?", before.UTC())
+	return err
+}
+
+// GetBurstLimit returns how many embeds can be posted in the channel per period across all users.
+// A limit of 0 means the channel has no burst limit.
+func (s *SQLiteStorage) GetBurstLimit(channelID uint64) (int, time.Duration, error) {
+	var limit int
+	var seconds int64
+	err := s.db.QueryRow("SELECT burst_limit, burst_period FROM channel_settings WHERE channel_id = ?", channelID).Scan(&limit, &seconds)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return limit, time.Duration(seconds) * time.Second, err
+}
+
+func (s *SQLiteStorage) SetBurstLimit(channelID uint64, limit int, period time.Duration) error {
+	seconds := int64(period / time.Second)
+	_, err := s.db.Exec(`
+		INSERT INTO channel_settings (channel_id, burst_limit, burst_period)
+		VALUES (?, ?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET burst_limit = ?, burst_period = ?
+	`, channelID, limit, seconds, limit, seconds)
+	return err
+}
+
+func (s *SQLiteStorage) GetGuildTimezone(guildID uint64) (string, error) {
+	var timezone string
+	err := s.db.QueryRow("SELECT timezone FROM guild_settings WHERE guild_id = ?", guildID).Scan(&timezone)
+	if err == sql.ErrNoRows {
+		return DefaultTimezone, nil
+	}
+	return timezone, err
+}
+
+func (s *SQLiteStorage) SetGuildTimezone(guildID uint64, timezone string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO guild_settings (guild_id, timezone)
+		VALUES (?, ?)
+		ON CONFLICT(guild_id) DO UPDATE SET timezone = ?
+	`, guildID, timezone, timezone)
+	return err
+}
+
+// GetGuildLocale returns the preferred locale of the guild as last seen in an interaction, empty if unknown.
+func (s *SQLiteStorage) GetGuildLocale(guildID uint64) (string, error) {
+	var locale string
+	err := s.db.QueryRow("SELECT locale FROM guild_settings WHERE guild_id = ?", guildID).Scan(&locale)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return locale, err
+}
+
+func (s *SQLiteStorage) SetGuildLocale(guildID uint64, locale string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO guild_settings (guild_id, locale)
+		VALUES (?, ?)
+		ON CONFLICT(guild_id) DO UPDATE SET locale = ?
+	`, guildID, locale, locale)
+	return err
+}
+
+// GetGuildHintDelivery returns where hints are sent to users who have not chosen, "dm" or "channel".
+func (s *SQLiteStorage) GetGuildHintDelivery(guildID uint64) (string, error) {
+	var delivery string
+	err := s.db.QueryRow("SELECT hint_delivery FROM guild_settings WHERE guild_id = ?", guildID).Scan(&delivery)
+	if err == sql.ErrNoRows {
+		return "dm", nil
+	}
+	return delivery, err
+}
+
+func (s *SQLiteStorage) SetGuildHintDelivery(guildID uint64, delivery string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO guild_settings (guild_id, hint_delivery)
+		VALUES (?, ?)
+		ON CONFLICT(guild_id) DO UPDATE SET hint_delivery = ?
+	`, guildID, delivery, delivery)
+	return err
+}
+
+// GetHintTemplate returns the guild's template of the hint for the reason, empty if the guild uses the default hint.
+func (s *SQLiteStorage) GetHintTemplate(guildID uint64, reason string) (string, error) {
+	var template string
+	err := s.db.QueryRow("SELECT template FROM hint_template WHERE guild_id = ? AND reason = ?", guildID, reason).Scan(&template)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return template, err
+}
+
+// SetHintTemplate sets the guild's template of the hint for the reason, an empty template restores the default hint.
+func (s *SQLiteStorage) SetHintTemplate(guildID uint64, reason string, template string) error {
+	if template == "" {
+		_, err := s.db.Exec("DELETE FROM hint_template WHERE guild_id = ? AND reason = ?", guildID, reason)
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO hint_template (guild_id, reason, template)
+		VALUES (?, ?, ?)
+		ON CONFLICT(guild_id, reason) DO UPDATE SET template = ?
+	`, guildID, reason, template, template)
+	return err
+}
+
+// Schedule is a weekly time range, in the guild's timezone, in which throttling is active.
+type Schedule struct {
+	ID int64
+	// Weekday is a time.Weekday, or -1 for every day.
+	Weekday int
+	// StartMinute and EndMinute are minutes since midnight. The range wraps past midnight if EndMinute < StartMinute,
+	// and covers the whole day if they are equal.
+	StartMinute int
+	EndMinute   int
+}
+
+// Contains reports whether the local time is inside the schedule.
+func (s Schedule) Contains(at time.Time) bool {
+	minute := at.Hour()*60 + at.Minute()
+	weekday := int(at.Weekday())
+	onDay := func(day int) bool {
+		return s.Weekday < 0 || s.Weekday == day
+	}
+
+	switch {
+	case s.StartMinute == s.EndMinute:
+		return onDay(weekday)
+	case s.StartMinute < s.EndMinute:
+		return onDay(weekday) && minute >= s.StartMinute && minute < s.EndMinute
+	case minute >= s.StartMinute:
+		return onDay(weekday)
+	case minute < s.EndMinute:
+		// After midnight, the range belongs to the previous day
+		return onDay((weekday + 6) % 7)
+	default:
+		return false
+	}
+}
+
+func (s *SQLiteStorage) GetChannelSchedules(channelID uint64) ([]Schedule, error) {
+	var schedules []Schedule
+	rows, err := s.db.Query("SELECT id, weekday, start_minute, end_minute FROM channel_schedule WHERE channel_id = ? ORDER BY weekday, start_minute", channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schedule Schedule
+		err := rows.Scan(&schedule.ID, &schedule.Weekday, &schedule.StartMinute, &schedule.EndMinute)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *SQLiteStorage) AddChannelSchedule(channelID uint64, schedule Schedule) (int64, error) {
+	result, err := s.db.Exec("INSERT INTO channel_schedule (channel_id, weekday, start_minute, end_minute) VALUES (?, ?, ?, ?)",
+		channelID, schedule.Weekday, schedule.StartMinute, schedule.EndMinute)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// RemoveChannelSchedule returns false if the channel has no such schedule.
+func (s *SQLiteStorage) RemoveChannelSchedule(channelID uint64, scheduleID int64) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM channel_schedule WHERE channel_id = ? AND id = ?", channelID, scheduleID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// IsChannelScheduledAt reports whether throttling is active in the channel at the local time.
+// Channels without schedules are always active.
+func (s *SQLiteStorage) IsChannelScheduledAt(channelID uint64, at time.Time) (bool, error) {
+	schedules, err := s.GetChannelSchedules(channelID)
+	if err != nil {
+		return false, err
+	}
+	if len(schedules) == 0 {
+		return true, nil
+	}
+	for _, schedule := range schedules {
+		if schedule.Contains(at) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsChannelShadow reports whether throttling decisions in the channel are only recorded, not enforced.
+func (s *SQLiteStorage) IsChannelShadow(channelID uint64) (bool, error) {
+	var shadow bool
+	err := s.db.QueryRow("SELECT shadow FROM channel_settings WHERE channel_id = ?", channelID).Scan(&shadow)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return shadow, err
+}
+
+func (s *SQLiteStorage) SetChannelShadow(channelID uint64, shadow bool) error {
+	_, err := s.db.Exec(`
+		INSERT INTO channel_settings (channel_id, shadow)
+		VALUES (?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET shadow = ?
+	`, channelID, shadow, shadow)
+	return err
+}
+
+// ShadowDecision is what the bot would have done with a message in a channel in shadow mode.
+type ShadowDecision struct {
+	ChannelID uint64
+	MessageID uint64
+	UserID    uint64
+	// Decision is "allow", or the reason the message would have been suppressed.
+	Decision  string
+	Cost      int
+	DecidedAt time.Time
+}
+
+type ShadowUser struct {
+	UserID     uint64
+	Suppressed int
+}
+
+// RecordShadowDecision records the decision and counts it, a message is only counted once.
+func (s *SQLiteStorage) RecordShadowDecision(decision ShadowDecision) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO shadow_decision (channel_id, message_id, user_id, decision, cost, decided_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(channel_id, message_id) DO NOTHING
+	`, decision.ChannelID, decision.MessageID, decision.UserID, decision.Decision, decision.Cost, decision.DecidedAt.UTC())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO shadow_count (channel_id, decision, count)
+		VALUES (?, ?, 1)
+		ON CONFLICT(channel_id, decision) DO UPDATE SET count = count + 1
+	`, decision.ChannelID, decision.Decision)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetShadowCounts returns how many messages got each decision since the shadow decisions were last reset.
+func (s *SQLiteStorage) GetShadowCounts(channelID uint64) (map[string]int, error) {
+	rows, err := s.db.Query("SELECT decision, count FROM shadow_count WHERE channel_id = ?", channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var decision string
+		var count int
+		if err := rows.Scan(&decision, &count); err != nil {
+			return nil, err
+		}
+		counts[decision] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetShadowSuppressedUsers returns the users who would have had the most messages suppressed.
+func (s *SQLiteStorage) GetShadowSuppressedUsers(channelID uint64, limit int) ([]ShadowUser, error) {
+	rows, err := s.db.Query(`
+		SELECT user_id, COUNT(*) AS suppressed FROM shadow_decision
+		WHERE channel_id = ? AND decision != 'allow'
+		GROUP BY user_id ORDER BY suppressed DESC, user_id LIMIT ?
+	`, channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []ShadowUser
+	for rows.Next() {
+		var user ShadowUser
+		if err := rows.Scan(&user.UserID, &user.Suppressed); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStorage) ResetShadowDecisions(channelID uint64) error {
+	_, err := s.db.Exec("DELETE FROM shadow_decision WHERE channel_id = ?", channelID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM shadow_count WHERE channel_id = ?", channelID)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"shadow_quota_usage", "shadow_attachment_usage"} {
+		_, err = s.db.Exec("DELETE FROM "+table+" WHERE channel_id = ?", channelID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getShadowUsage is the usage the user would have had in shadow mode, 0 if none has been tracked.
+func (s *SQLiteStorage) getShadowUsage(table string, userID, channelID uint64) (int, error) {
+	count, err := s.getUsage(table, userID, channelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return count, err
+}
+
+// The shadow usage is tracked apart from the usage, so shadow mode doesn't spend the quotas of the users.
+
+func (s *SQLiteStorage) GetShadowQuotaUsage(userID, channelID uint64) (int, error) {
+	return s.getShadowUsage("shadow_quota_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) IncreaseShadowQuotaUsage(userID, channelID uint64, delta int) (int, error) {
+	return s.increaseUsage("shadow_quota_usage", userID, channelID, delta)
+}
+
+func (s *SQLiteStorage) GetShadowAttachmentUsage(userID, channelID uint64) (int, error) {
+	return s.getShadowUsage("shadow_attachment_usage", userID, channelID)
+}
+
+func (s *SQLiteStorage) IncreaseShadowAttachmentUsage(userID, channelID uint64, delta int) (int, error) {
+	return s.increaseUsage("shadow_attachment_usage", userID, channelID, delta)
+}
+
+// GetBotAdminRoles returns the roles whose members can use the commands of the bot without their permissions.
+func (s *SQLiteStorage) GetBotAdminRoles(guildID uint64) ([]uint64, error) {
+	rows, err := s.db.Query("SELECT role_id FROM bot_admin_role WHERE guild_id = ? ORDER BY role_id", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roleIDs []uint64
+	for rows.Next() {
+		var roleID uint64
+		if err := rows.Scan(&roleID); err != nil {
+			return nil, err
+		}
+		roleIDs = append(roleIDs, roleID)
+	}
+	return roleIDs, rows.Err()
+}
+
+func (s *SQLiteStorage) AddBotAdminRole(guildID, roleID uint64) error {
+	_, err := s.db.Exec("INSERT INTO bot_admin_role (guild_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING", guildID, roleID)
+	return err
+}
+
+// RemoveBotAdminRole reports whether the role was a bot-admin role of the guild.
+func (s *SQLiteStorage) RemoveBotAdminRole(guildID, roleID uint64) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM bot_admin_role WHERE guild_id = ? AND role_id = ?", guildID, roleID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
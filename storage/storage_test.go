@@ -1,15 +1,18 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/No3371/dc_embed_throttler/clock"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestSQLiteStorage_ConcurrentReadWriteRestoreCount(t *testing.T) {
-	db, err := NewSQLiteStorage("et.db?_journal_mode=WAL&mode=memory&_sync=1&_txlock=immediate")
+	db, err := NewSQLiteStorage("et.db?_journal_mode=WAL&mode=memory&_sync=1&_txlock=immediate", clock.System)
 	if err != nil {
 		t.Fatalf("Failed to create SQLiteStorage: %v", err)
 	}
@@ -69,3 +72,506 @@ func TestBeginingOfTheDay (t *testing.T) {
 	taipeiTime := time.Now().UTC().Add(time.Hour*8).Truncate(time.Hour * 24)
 	t.Logf("Begining of the day (TPE): %v", taipeiTime)
 }
+
+func newMemoryStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	db, err := NewSQLiteStorage("file:"+t.Name()+"?mode=memory&cache=shared", clock.System)
+	if err != nil {
+		t.Fatalf("Failed to create SQLiteStorage: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteStorage_EmbedCosts(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	guildID := uint64(1)
+	if err := db.SetEmbedCost(guildID, "image", "", 0); err != nil {
+		t.Fatalf("Failed to set embed cost: %v", err)
+	}
+	if err := db.SetEmbedCost(guildID, "video", "YouTube", 2); err != nil {
+		t.Fatalf("Failed to set embed cost: %v", err)
+	}
+	if err := db.SetEmbedCost(guildID, "video", "YouTube", 3); err != nil {
+		t.Fatalf("Failed to update embed cost: %v", err)
+	}
+	if err := db.SetEmbedCost(guildID+1, "link", "", 5); err != nil {
+		t.Fatalf("Failed to set embed cost: %v", err)
+	}
+
+	costs, err := db.GetEmbedCosts(guildID)
+	if err != nil {
+		t.Fatalf("Failed to get embed costs: %v", err)
+	}
+	expected := []EmbedCost{{"image", "", 0}, {"video", "YouTube", 3}}
+	if len(costs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, costs)
+	}
+	for i := range expected {
+		if costs[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, costs)
+		}
+	}
+
+	if err := db.SetEmbedCost(guildID, "image", "", -1); err != nil {
+		t.Fatalf("Failed to remove embed cost: %v", err)
+	}
+	costs, err = db.GetEmbedCosts(guildID)
+	if err != nil {
+		t.Fatalf("Failed to get embed costs: %v", err)
+	}
+	if len(costs) != 1 {
+		t.Fatalf("Expected 1 embed cost after removal, got %v", costs)
+	}
+}
+
+func TestSQLiteStorage_AttachmentQuota(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	userID := uint64(1)
+	channelID := uint64(2)
+	quota, err := db.GetAttachmentQuota(channelID)
+	if err != nil {
+		t.Fatalf("Failed to get attachment quota: %v", err)
+	}
+	if quota != -1 {
+		t.Fatalf("Expected attachment throttling to be disabled by default, got %d", quota)
+	}
+
+	if err := db.SetChannelEnabled(channelID, true); err != nil {
+		t.Fatalf("Failed to enable channel: %v", err)
+	}
+	if err := db.SetAttachmentQuota(channelID, 5); err != nil {
+		t.Fatalf("Failed to set attachment quota: %v", err)
+	}
+	quota, err = db.GetAttachmentQuota(channelID)
+	if err != nil || quota != 5 {
+		t.Fatalf("Expected attachment quota 5, got %d (%v)", quota, err)
+	}
+	enabled, err := db.IsChannelEnabled(channelID)
+	if err != nil || !enabled {
+		t.Fatalf("Setting attachment quota should not affect channel status, got %v (%v)", enabled, err)
+	}
+
+	if _, err := db.IncreaseAttachmentUsage(userID, channelID, 2); err != nil {
+		t.Fatalf("Failed to increase attachment usage: %v", err)
+	}
+	usage, err := db.IncreaseAttachmentUsage(userID, channelID, 2)
+	if err != nil || usage != 4 {
+		t.Fatalf("Expected attachment usage 4, got %d (%v)", usage, err)
+	}
+	quotaUsage, err := db.GetQuotaUsage(userID, channelID)
+	if err == nil || quotaUsage != 0 {
+		t.Fatalf("Attachment usage should not be counted as embed quota usage, got %d (%v)", quotaUsage, err)
+	}
+
+	if err := db.ResetAttachmentUsage(userID, channelID); err != nil {
+		t.Fatalf("Failed to reset attachment usage: %v", err)
+	}
+	usage, err = db.GetAttachmentUsage(userID, channelID)
+	if err != nil || usage != 0 {
+		t.Fatalf("Expected attachment usage 0 after reset, got %d (%v)", usage, err)
+	}
+}
+
+func TestSQLiteStorage_MigrateChannelSettings(t *testing.T) {
+	path := "file:" + t.Name() + "?mode=memory&cache=shared"
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer legacy.Close()
+	_, err = legacy.Exec(`
+		CREATE TABLE channel_settings (
+			channel_id INTEGER PRIMARY KEY,
+			enabled BOOLEAN DEFAULT 0,
+			suppress_bot BOOLEAN DEFAULT TRUE
+		);
+		INSERT INTO channel_settings (channel_id, enabled) VALUES (1, 1);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	db, err := NewSQLiteStorage(path, clock.System)
+	if err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	defer db.Close()
+
+	quota, err := db.GetAttachmentQuota(1)
+	if err != nil || quota != -1 {
+		t.Fatalf("Expected migrated attachment quota -1, got %d (%v)", quota, err)
+	}
+}
+
+func TestSQLiteStorage_BotReply(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	if _, err := db.GetBotReply(1); err != sql.ErrNoRows {
+		t.Fatalf("Expected sql.ErrNoRows, got %v", err)
+	}
+	if err := db.SetBotReply(1, 2, 3); err != nil {
+		t.Fatalf("Failed to set bot reply: %v", err)
+	}
+	if err := db.SetBotReply(1, 2, 4); err != nil {
+		t.Fatalf("Failed to update bot reply: %v", err)
+	}
+	replyID, err := db.GetBotReply(1)
+	if err != nil || replyID != 4 {
+		t.Fatalf("Expected reply 4, got %d (%v)", replyID, err)
+	}
+
+	replyID, err = db.DeleteBotReply(1)
+	if err != nil || replyID != 4 {
+		t.Fatalf("Expected deleted reply 4, got %d (%v)", replyID, err)
+	}
+	if _, err := db.DeleteBotReply(1); err != sql.ErrNoRows {
+		t.Fatalf("Expected sql.ErrNoRows after deletion, got %v", err)
+	}
+}
+
+func TestSQLiteStorage_RecentURL(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	channelID := uint64(1)
+	url := "https://example.com/a"
+	postedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.RecordRecentURL(channelID, url, 10, postedAt); err != nil {
+		t.Fatalf("Failed to record recent url: %v", err)
+	}
+
+	messageID, err := db.FindRecentURL(channelID, url, postedAt.Add(-time.Hour))
+	if err != nil || messageID != 10 {
+		t.Fatalf("Expected message 10, got %d (%v)", messageID, err)
+	}
+	if _, err := db.FindRecentURL(channelID, url, postedAt.Add(time.Minute)); err != sql.ErrNoRows {
+		t.Fatalf("Expected url to be outside of the window, got %v", err)
+	}
+	if _, err := db.FindRecentURL(channelID+1, url, postedAt.Add(-time.Hour)); err != sql.ErrNoRows {
+		t.Fatalf("Expected url to be unknown in another channel, got %v", err)
+	}
+
+	if err := db.PruneRecentURLs(postedAt.Add(time.Second)); err != nil {
+		t.Fatalf("Failed to prune recent urls: %v", err)
+	}
+	if _, err := db.FindRecentURL(channelID, url, postedAt.Add(-time.Hour)); err != sql.ErrNoRows {
+		t.Fatalf("Expected url to be pruned, got %v", err)
+	}
+
+	if err := db.SetDuplicateWindow(channelID, 30*time.Minute); err != nil {
+		t.Fatalf("Failed to set duplicate window: %v", err)
+	}
+	window, err := db.GetDuplicateWindow(channelID)
+	if err != nil || window != 30*time.Minute {
+		t.Fatalf("Expected 30m window, got %v (%v)", window, err)
+	}
+}
+
+func TestSchedule_Contains(t *testing.T) {
+	// 2025-01-06 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 1, 6+day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		at       time.Time
+		expected bool
+	}{
+		{"every day inside", Schedule{Weekday: -1, StartMinute: 18 * 60, EndMinute: 23 * 60}, at(3, 20, 0), true},
+		{"every day start is inclusive", Schedule{Weekday: -1, StartMinute: 18 * 60, EndMinute: 23 * 60}, at(3, 18, 0), true},
+		{"every day end is exclusive", Schedule{Weekday: -1, StartMinute: 18 * 60, EndMinute: 23 * 60}, at(3, 23, 0), false},
+		{"weekday matches", Schedule{Weekday: int(time.Monday), StartMinute: 9 * 60, EndMinute: 17 * 60}, at(0, 12, 0), true},
+		{"weekday does not match", Schedule{Weekday: int(time.Monday), StartMinute: 9 * 60, EndMinute: 17 * 60}, at(1, 12, 0), false},
+		{"overnight before midnight", Schedule{Weekday: int(time.Friday), StartMinute: 22 * 60, EndMinute: 2 * 60}, at(4, 23, 30), true},
+		{"overnight after midnight", Schedule{Weekday: int(time.Friday), StartMinute: 22 * 60, EndMinute: 2 * 60}, at(5, 1, 30), true},
+		{"overnight after midnight of another day", Schedule{Weekday: int(time.Friday), StartMinute: 22 * 60, EndMinute: 2 * 60}, at(4, 1, 30), false},
+		{"overnight outside", Schedule{Weekday: -1, StartMinute: 22 * 60, EndMinute: 2 * 60}, at(2, 12, 0), false},
+		{"whole day", Schedule{Weekday: int(time.Sunday), StartMinute: 0, EndMinute: 0}, at(6, 23, 59), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if contains := tt.schedule.Contains(tt.at); contains != tt.expected {
+				t.Errorf("Expected %v at %v, got %v", tt.expected, tt.at, contains)
+			}
+		})
+	}
+}
+
+func TestSQLiteStorage_ChannelSchedules(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	channelID := uint64(1)
+	noon := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+	scheduled, err := db.IsChannelScheduledAt(channelID, noon)
+	if err != nil || !scheduled {
+		t.Fatalf("Expected channels without schedules to be always active, got %v (%v)", scheduled, err)
+	}
+
+	id, err := db.AddChannelSchedule(channelID, Schedule{Weekday: -1, StartMinute: 18 * 60, EndMinute: 23 * 60})
+	if err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+	scheduled, err = db.IsChannelScheduledAt(channelID, noon)
+	if err != nil || scheduled {
+		t.Fatalf("Expected channel to be inactive at noon, got %v (%v)", scheduled, err)
+	}
+	scheduled, err = db.IsChannelScheduledAt(channelID, noon.Add(7*time.Hour))
+	if err != nil || !scheduled {
+		t.Fatalf("Expected channel to be active at 19:00, got %v (%v)", scheduled, err)
+	}
+
+	removed, err := db.RemoveChannelSchedule(channelID+1, id)
+	if err != nil || removed {
+		t.Fatalf("Expected schedule of another channel not to be removed, got %v (%v)", removed, err)
+	}
+	removed, err = db.RemoveChannelSchedule(channelID, id)
+	if err != nil || !removed {
+		t.Fatalf("Expected schedule to be removed, got %v (%v)", removed, err)
+	}
+	schedules, err := db.GetChannelSchedules(channelID)
+	if err != nil || len(schedules) != 0 {
+		t.Fatalf("Expected no schedules, got %v (%v)", schedules, err)
+	}
+}
+
+func TestSQLiteStorage_GuildTimezone(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	timezone, err := db.GetGuildTimezone(1)
+	if err != nil || timezone != DefaultTimezone {
+		t.Fatalf("Expected %s, got %s (%v)", DefaultTimezone, timezone, err)
+	}
+	if err := db.SetGuildTimezone(1, "Asia/Tokyo"); err != nil {
+		t.Fatalf("Failed to set timezone: %v", err)
+	}
+	timezone, err = db.GetGuildTimezone(1)
+	if err != nil || timezone != "Asia/Tokyo" {
+		t.Fatalf("Expected Asia/Tokyo, got %s (%v)", timezone, err)
+	}
+}
+
+func TestSQLiteStorage_QuotaResetsAtTaipeiMidnight(t *testing.T) {
+	// 23:50 in Taipei
+	clk := clock.NewFake(time.Date(2025, 1, 1, 15, 50, 0, 0, time.UTC))
+	db, err := NewSQLiteStorage("file:"+t.Name()+"?mode=memory&cache=shared", clk)
+	if err != nil {
+		t.Fatalf("Failed to create SQLiteStorage: %v", err)
+	}
+	defer db.Close()
+
+	userID := uint64(1)
+	channelID := uint64(2)
+	expectUsage := func(expected int) {
+		t.Helper()
+		usage, err := db.GetQuotaUsage(userID, channelID)
+		if err != nil {
+			t.Fatalf("Failed to get quota usage: %v", err)
+		}
+		if usage != expected {
+			t.Fatalf("Expected usage %d at %v, got %d", expected, clk.Now(), usage)
+		}
+	}
+
+	if _, err := db.IncreaseQuotaUsage(userID, channelID, 0); err != nil {
+		t.Fatalf("Failed to create quota usage: %v", err)
+	}
+	if _, err := db.IncreaseQuotaUsage(userID, channelID, 3); err != nil {
+		t.Fatalf("Failed to increase quota usage: %v", err)
+	}
+	expectUsage(3)
+
+	clk.Advance(5 * time.Minute)
+	expectUsage(3)
+
+	// 00:05 in Taipei
+	clk.Advance(10 * time.Minute)
+	expectUsage(0)
+
+	if _, err := db.IncreaseQuotaUsage(userID, channelID, 1); err != nil {
+		t.Fatalf("Failed to increase quota usage: %v", err)
+	}
+	clk.Advance(23 * time.Hour)
+	expectUsage(1)
+
+	clk.Advance(time.Hour)
+	expectUsage(0)
+}
+
+func TestSQLiteStorage_ShadowDecisions(t *testing.T) {
+	storage := newMemoryStorage(t)
+	channelID := uint64(1)
+	now := time.Now()
+
+	decisions := []ShadowDecision{
+		{ChannelID: channelID, MessageID: 1, UserID: 10, Decision: "allow", Cost: 1, DecidedAt: now},
+		{ChannelID: channelID, MessageID: 2, UserID: 10, Decision: "quota", Cost: 1, DecidedAt: now},
+		{ChannelID: channelID, MessageID: 3, UserID: 11, Decision: "quota", Cost: 1, DecidedAt: now},
+		{ChannelID: channelID, MessageID: 4, UserID: 10, Decision: "burst", Cost: 2, DecidedAt: now},
+		// Deferred messages can be decided on again
+		{ChannelID: channelID, MessageID: 4, UserID: 10, Decision: "burst", Cost: 2, DecidedAt: now},
+	}
+	for _, decision := range decisions {
+		if err := storage.RecordShadowDecision(decision); err != nil {
+			t.Fatalf("Failed to record shadow decision: %v", err)
+		}
+	}
+
+	counts, err := storage.GetShadowCounts(channelID)
+	if err != nil {
+		t.Fatalf("Failed to get shadow counts: %v", err)
+	}
+	if counts["allow"] != 1 || counts["quota"] != 2 || counts["burst"] != 1 {
+		t.Fatalf("Unexpected shadow counts: %v", counts)
+	}
+
+	users, err := storage.GetShadowSuppressedUsers(channelID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get shadow users: %v", err)
+	}
+	if len(users) != 2 || users[0] != (ShadowUser{UserID: 10, Suppressed: 2}) || users[1] != (ShadowUser{UserID: 11, Suppressed: 1}) {
+		t.Fatalf("Unexpected shadow users: %+v", users)
+	}
+
+	if err := storage.ResetShadowDecisions(channelID); err != nil {
+		t.Fatalf("Failed to reset shadow decisions: %v", err)
+	}
+	counts, err = storage.GetShadowCounts(channelID)
+	if err != nil || len(counts) != 0 {
+		t.Fatalf("Expected no shadow counts after reset, got %v, %v", counts, err)
+	}
+}
+
+func TestSQLiteStorage_ShadowUsage(t *testing.T) {
+	storage := newMemoryStorage(t)
+	userID, channelID := uint64(10), uint64(1)
+
+	if usage, err := storage.GetShadowQuotaUsage(userID, channelID); err != nil || usage != 0 {
+		t.Fatalf("Expected no shadow quota usage, got %d (%v)", usage, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := storage.IncreaseShadowQuotaUsage(userID, channelID, 1); err != nil {
+			t.Fatalf("Failed to increase shadow quota usage: %v", err)
+		}
+	}
+	if _, err := storage.IncreaseShadowAttachmentUsage(userID, channelID, 3); err != nil {
+		t.Fatalf("Failed to increase shadow attachment usage: %v", err)
+	}
+	if usage, err := storage.GetShadowQuotaUsage(userID, channelID); err != nil || usage != 2 {
+		t.Fatalf("Expected shadow quota usage 2, got %d (%v)", usage, err)
+	}
+	if usage, err := storage.GetShadowAttachmentUsage(userID, channelID); err != nil || usage != 3 {
+		t.Fatalf("Expected shadow attachment usage 3, got %d (%v)", usage, err)
+	}
+
+	// The live usage is left alone
+	if _, err := storage.GetQuotaUsage(userID, channelID); err != sql.ErrNoRows {
+		t.Fatalf("Expected no quota usage, got %v", err)
+	}
+	if _, err := storage.GetAttachmentUsage(userID, channelID); err != sql.ErrNoRows {
+		t.Fatalf("Expected no attachment usage, got %v", err)
+	}
+
+	if err := storage.ResetShadowDecisions(channelID); err != nil {
+		t.Fatalf("Failed to reset shadow decisions: %v", err)
+	}
+	if usage, err := storage.GetShadowQuotaUsage(userID, channelID); err != nil || usage != 0 {
+		t.Fatalf("Expected no shadow quota usage after reset, got %d (%v)", usage, err)
+	}
+	if usage, err := storage.GetShadowAttachmentUsage(userID, channelID); err != nil || usage != 0 {
+		t.Fatalf("Expected no shadow attachment usage after reset, got %d (%v)", usage, err)
+	}
+}
+
+func TestSQLiteStorage_RemoveAndCopyRoleQuotas(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	from, to, other := uint64(1), uint64(2), uint64(3)
+	for _, q := range []RoleQuota{{RoleID: 10, Quota: 5, Priority: 1}, {RoleID: 11, Quota: 0, Priority: 2}} {
+		if err := db.ConfigureRoleQuota(from, q.RoleID, q.Quota, q.Priority); err != nil {
+			t.Fatalf("Failed to configure role quota: %v", err)
+		}
+	}
+	if err := db.ConfigureRoleQuota(to, 10, 1, 0); err != nil {
+		t.Fatalf("Failed to configure role quota: %v", err)
+	}
+
+	n, err := db.CopyRoleQuotas(from, []uint64{from, to, other})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 quotas to be copied, got %d (%v)", n, err)
+	}
+	for _, channelID := range []uint64{to, other} {
+		quotas, err := db.GetAllRoleQuotas(channelID)
+		if err != nil || len(quotas) != 2 || quotas[1] != (RoleQuota{RoleID: 10, Quota: 5, Priority: 1}) {
+			t.Fatalf("Expected the quotas of channel %d to be replaced, got %v (%v)", channelID, quotas, err)
+		}
+	}
+
+	removed, err := db.RemoveRoleQuota(to, 10)
+	if err != nil || !removed {
+		t.Fatalf("Expected the role quota to be removed, got %v (%v)", removed, err)
+	}
+	removed, err = db.RemoveRoleQuota(to, 10)
+	if err != nil || removed {
+		t.Fatalf("Expected nothing to be removed, got %v (%v)", removed, err)
+	}
+	quotas, err := db.GetAllRoleQuotas(from)
+	if err != nil || len(quotas) != 2 {
+		t.Fatalf("Expected the source channel to keep its quotas, got %v (%v)", quotas, err)
+	}
+}
+
+func TestSQLiteStorage_NegativeRoleQuotas(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	for _, q := range []RoleQuota{{RoleID: 10, Quota: -1, Priority: 0}, {RoleID: 10, Quota: 1, Priority: -1}} {
+		if err := db.ConfigureRoleQuota(1, q.RoleID, q.Quota, q.Priority); !errors.Is(err, ErrNegativeRoleQuota) {
+			t.Fatalf("Expected %+v to be rejected, got %v", q, err)
+		}
+	}
+
+	// Quotas stored before they were validated aren't copied either
+	if _, err := db.db.Exec("INSERT INTO role (role_id, channel_id, quota, priority) VALUES (10, 1, -1, 0)"); err != nil {
+		t.Fatalf("Failed to insert role quota: %v", err)
+	}
+	if _, err := db.CopyRoleQuotas(1, []uint64{2}); !errors.Is(err, ErrNegativeRoleQuota) {
+		t.Fatalf("Expected the negative quota to be rejected, got %v", err)
+	}
+	if quotas, err := db.GetAllRoleQuotas(2); err != nil || len(quotas) != 0 {
+		t.Fatalf("Expected no quotas to be copied, got %v (%v)", quotas, err)
+	}
+}
+
+func TestSQLiteStorage_BotAdminRoles(t *testing.T) {
+	db := newMemoryStorage(t)
+
+	for _, roleID := range []uint64{20, 10, 20} {
+		if err := db.AddBotAdminRole(1, roleID); err != nil {
+			t.Fatalf("Failed to add bot-admin role: %v", err)
+		}
+	}
+	if err := db.AddBotAdminRole(2, 30); err != nil {
+		t.Fatalf("Failed to add bot-admin role: %v", err)
+	}
+	roleIDs, err := db.GetBotAdminRoles(1)
+	if err != nil || len(roleIDs) != 2 || roleIDs[0] != 10 || roleIDs[1] != 20 {
+		t.Fatalf("Expected roles 10 and 20, got %v (%v)", roleIDs, err)
+	}
+
+	removed, err := db.RemoveBotAdminRole(1, 30)
+	if err != nil || removed {
+		t.Fatalf("Expected the role of another guild not to be removed, got %v (%v)", removed, err)
+	}
+	removed, err = db.RemoveBotAdminRole(1, 10)
+	if err != nil || !removed {
+		t.Fatalf("Expected the role to be removed, got %v (%v)", removed, err)
+	}
+	roleIDs, err = db.GetBotAdminRoles(1)
+	if err != nil || len(roleIDs) != 1 || roleIDs[0] != 20 {
+		t.Fatalf("Expected role 20, got %v (%v)", roleIDs, err)
+	}
+}